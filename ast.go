@@ -0,0 +1,142 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import "strconv"
+
+// Part is a node of a parsed URI Template; either *Literal or *Expression.
+type Part interface {
+	// Pos returns the byte offset of the part in the raw template.
+	Pos() int
+	// End returns the byte offset immediately after the part.
+	End() int
+
+	part()
+}
+
+// Literal represents a run of literal characters.
+type Literal struct {
+	text string
+	pos  int
+}
+
+func (*Literal) part() {}
+
+// Text returns the literal characters as written in the template.
+func (l *Literal) Text() string {
+	return l.text
+}
+
+func (l *Literal) Pos() int {
+	return l.pos
+}
+
+func (l *Literal) End() int {
+	return l.pos + len(l.text)
+}
+
+// Expression represents an expression enclosed in braces.
+type Expression struct {
+	expr *expression
+}
+
+func (*Expression) part() {}
+
+// Operator returns the operator of the expression.
+func (e *Expression) Operator() Operator {
+	return e.expr.op
+}
+
+// Varspecs returns the variable specifications in the expression.
+func (e *Expression) Varspecs() []Varspec {
+	ret := make([]Varspec, len(e.expr.vars))
+	copy(ret, e.expr.vars)
+	return ret
+}
+
+// String returns the expression as written in a template.
+func (e *Expression) String() string {
+	return e.expr.String()
+}
+
+func (e *Expression) Pos() int {
+	return e.expr.pos
+}
+
+func (e *Expression) End() int {
+	return e.expr.end
+}
+
+// Varspec represents a variable specification in an expression.
+type Varspec struct {
+	name    string
+	maxlen  int
+	explode bool
+
+	pos int
+	end int
+}
+
+// Name returns the variable name.
+func (v Varspec) Name() string {
+	return v.name
+}
+
+// MaxLen returns the max-length of the prefix modifier, or 0 if the
+// variable has no prefix modifier.
+func (v Varspec) MaxLen() int {
+	return v.maxlen
+}
+
+// Exploded reports whether or not the variable has the explode modifier.
+func (v Varspec) Exploded() bool {
+	return v.explode
+}
+
+// Pos returns the byte offset of the varspec in the raw template.
+func (v Varspec) Pos() int {
+	return v.pos
+}
+
+// End returns the byte offset immediately after the varspec.
+func (v Varspec) End() int {
+	return v.end
+}
+
+// String returns the varspec as written in a template.
+func (v Varspec) String() string {
+	switch {
+	case v.maxlen > 0:
+		return v.name + ":" + strconv.Itoa(v.maxlen)
+	case v.explode:
+		return v.name + "*"
+	default:
+		return v.name
+	}
+}
+
+// Parts returns the parsed nodes of the template in order of appearance.
+func (t *Template) Parts() []Part {
+	parts := make([]Part, 0, len(t.exprs))
+	pos := 0
+	for i := range t.exprs {
+		switch expr := t.exprs[i].(type) {
+		default:
+			panic("unhandled expression")
+		case literals:
+			parts = append(parts, &Literal{
+				text: string(expr),
+				pos:  pos,
+			})
+			pos += len(expr)
+		case *expression:
+			parts = append(parts, &Expression{expr: expr})
+			pos = expr.end
+		}
+	}
+	return parts
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleTemplate_Parts() {
+	tmpl := MustNew("https://example.com/dictionary/{term:1}{/term,tags*}")
+	for _, part := range tmpl.Parts() {
+		switch part := part.(type) {
+		case *Literal:
+			fmt.Printf("literal %q\n", part.Text())
+		case *Expression:
+			fmt.Printf("expression %q\n", part.Operator())
+			for _, spec := range part.Varspecs() {
+				fmt.Printf("  %s maxlen=%d explode=%v\n", spec.Name(), spec.MaxLen(), spec.Exploded())
+			}
+		}
+	}
+
+	// Output:
+	// literal "https://example.com/dictionary/"
+	// expression ""
+	//   term maxlen=1 explode=false
+	// expression "/"
+	//   term maxlen=0 explode=false
+	//   tags maxlen=0 explode=true
+}
+
+func TestTemplate_Parts(t *testing.T) {
+	raw := "/x/{a:12}{?b*,c}y"
+	tmpl := MustNew(raw)
+	parts := tmpl.Parts()
+
+	var spans []string
+	for _, part := range parts {
+		spans = append(spans, raw[part.Pos():part.End()])
+		if expr, ok := part.(*Expression); ok {
+			for _, spec := range expr.Varspecs() {
+				spans = append(spans, raw[spec.Pos():spec.End()])
+			}
+		}
+	}
+	expected := []string{"/x/", "{a:12}", "a:12", "{?b*,c}", "b*", "c", "y"}
+	if len(spans) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, spans)
+	}
+	for i := range expected {
+		if spans[i] != expected[i] {
+			t.Errorf("%d: expected %q, got %q", i, expected[i], spans[i])
+		}
+	}
+}
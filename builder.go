@@ -0,0 +1,136 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Builder constructs a Template programmatically.
+// The first error found is reported by Build.
+type Builder struct {
+	b   strings.Builder
+	err error
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Var returns a Varspec for the variable name without modifiers.
+func Var(name string) Varspec {
+	return Varspec{name: name}
+}
+
+// Explode returns a copy of v with the explode modifier.
+func (v Varspec) Explode() Varspec {
+	v.explode = true
+	return v
+}
+
+// Prefix returns a copy of v with the prefix modifier of maxlen.
+// A maxlen of 0 removes the prefix modifier.
+func (v Varspec) Prefix(maxlen int) Varspec {
+	v.maxlen = maxlen
+	return v
+}
+
+// Literal appends literal characters to the template.
+// Characters that are not allowed in literals, including '%', '{' and '}',
+// are pct-encoded.
+func (b *Builder) Literal(s string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r != utf8.RuneError && unicode.Is(rangeLiterals, r) {
+			b.b.WriteString(s[i : i+size])
+		} else {
+			for j := i; j < i+size; j++ {
+				c := s[j]
+				writeTriplet(&b.b, c)
+			}
+		}
+		i += size
+	}
+	return b
+}
+
+// Expr appends an expression consisting of op and vars to the template.
+func (b *Builder) Expr(op Operator, vars ...Varspec) *Builder {
+	if b.err != nil {
+		return b
+	}
+
+	pos := b.b.Len()
+	var expr strings.Builder
+	expr.WriteByte('{')
+	expr.WriteString(op.String())
+	offsets := make([]int, len(vars))
+	for i, spec := range vars {
+		if i > 0 {
+			expr.WriteByte(',')
+		}
+		offsets[i] = pos + expr.Len()
+		expr.WriteString(spec.String())
+	}
+	expr.WriteByte('}')
+	b.b.WriteString(expr.String())
+
+	switch {
+	case op < OpSimple || opLast <= op:
+		b.err = newParseError(b.b.String(), pos+1, pos, ErrReservedOperator)
+	case len(vars) == 0:
+		b.err = newParseError(b.b.String(), pos+1, pos, ErrInvalidVarname)
+	}
+	for i := 0; b.err == nil && i < len(vars); i++ {
+		spec := vars[i]
+		switch {
+		case !isValidVarspecName(spec.name):
+			b.err = newParseError(b.b.String(), offsets[i], pos, ErrInvalidVarname)
+		case spec.maxlen < 0 || spec.maxlen > 9999:
+			b.err = newParseError(b.b.String(), offsets[i]+len(spec.name)+1, pos, ErrInvalidMaxlen)
+		case spec.maxlen > 0 && spec.explode:
+			b.err = newParseError(b.b.String(), offsets[i]+len(spec.name), pos, ErrInvalidModifier)
+		}
+	}
+	return b
+}
+
+// Build returns the Template constructed so far.
+func (b *Builder) Build() (*Template, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return New(b.b.String())
+}
+
+// isValidVarspecName reports whether name consists of varchars and dots
+// in the same way as parser accepts.
+func isValidVarspecName(name string) bool {
+	if !isValidVarname(name) {
+		return false
+	}
+	for i := 0; i < len(name); {
+		switch c := name[i]; {
+		case c == '%':
+			if len(name)-i < 3 || !ishex(name[i+1]) || !ishex(name[i+2]) {
+				return false
+			}
+			i += 3
+		case c == '.' || (c < utf8.RuneSelf && unicode.Is(rangeVarchar, rune(c))):
+			i++
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleBuilder() {
+	tmpl, err := NewBuilder().
+		Literal("/users").
+		Expr(OpSlash, Var("id"), Var("tags").Explode()).
+		Literal("/100% {real}").
+		Expr(OpQuestion, Var("q").Prefix(10)).
+		Build()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(tmpl.Raw())
+
+	// Output:
+	// /users{/id,tags*}/100%25%20%7Breal%7D{?q:10}
+}
+
+func TestBuilder_Error(t *testing.T) {
+	for _, c := range []struct {
+		b   *Builder
+		err error
+	}{
+		{NewBuilder().Expr(OpSimple), ErrInvalidVarname},
+		{NewBuilder().Expr(OpSimple, Var("a,b")), ErrInvalidVarname},
+		{NewBuilder().Expr(OpSimple, Var("a..b")), ErrInvalidVarname},
+		{NewBuilder().Expr(OpSimple, Var("a%2")), ErrInvalidVarname},
+		{NewBuilder().Expr(OpSimple, Var("a").Prefix(10000)), ErrInvalidMaxlen},
+		{NewBuilder().Expr(OpSimple, Var("a").Prefix(1).Explode()), ErrInvalidModifier},
+		{NewBuilder().Expr(Operator(100), Var("a")), ErrReservedOperator},
+	} {
+		_, err := c.b.Build()
+		if !errors.Is(err, c.err) {
+			t.Errorf("on %q: expected %v, got %v", c.b.b.String(), c.err, err)
+		}
+	}
+}
+
+func TestBuilder_Equals(t *testing.T) {
+	tmpl, err := NewBuilder().
+		Literal("http://example.com/").
+		Expr(OpSimple, Var("a.b"), Var("c%2F").Prefix(3)).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := MustNew("http://example.com/{a.b,c%2F:3}"); !Equals(tmpl, expected, CompareVarname) {
+		t.Errorf("expected %q, got %q", expected.Raw(), tmpl.Raw())
+	}
+}
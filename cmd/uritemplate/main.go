@@ -0,0 +1,474 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+// Command uritemplate expands, matches and inspects URI Templates.
+//
+// Usage:
+//
+//	uritemplate expand [-var name=value] [-list name=a,b] [-kv name=k,v] [-json file] [-env] template
+//	uritemplate match [-kv name] [-unordered-query] template uri
+//	uritemplate regexp [-named] [-dialect re2|ecmascript|pcre] template
+//	uritemplate vars template
+//	uritemplate lint [template ...]
+//
+// lint reports parse errors and the diagnostics of Lint. It reads
+// templates from the standard input, one per line, if no template is
+// given, and exits with 1 if it reports anything but info.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yosida95/uritemplate/v3"
+)
+
+// cli is the environment the command runs in.
+type cli struct {
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+	lookupEnv func(string) (string, bool)
+}
+
+var commands = []struct {
+	name string
+	run  func(c *cli, args []string) int
+}{
+	{"expand", (*cli).runExpand},
+	{"match", (*cli).runMatch},
+	{"regexp", (*cli).runRegexp},
+	{"vars", (*cli).runVars},
+	{"lint", (*cli).runLint},
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: uritemplate <command> [arguments]")
+	fmt.Fprintln(c.stderr)
+	fmt.Fprintln(c.stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "\t%s\n", cmd.name)
+	}
+	fmt.Fprintln(c.stderr)
+	fmt.Fprintln(c.stderr, `Run "uritemplate <command> -h" for the usage of a command.`)
+}
+
+func main() {
+	c := &cli{
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+		lookupEnv: os.LookupEnv,
+	}
+	os.Exit(c.run(os.Args[1:]))
+}
+
+// run runs the command with args, which do not include the program name,
+// and returns the exit status.
+func (c *cli) run(args []string) int {
+	if len(args) < 1 {
+		c.usage()
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(c, args[1:])
+		}
+	}
+	fmt.Fprintf(c.stderr, "uritemplate: unknown command %q\n", args[0])
+	c.usage()
+	return 2
+}
+
+func (c *cli) newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: uritemplate %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args with fs. If it fails, parseFlags returns false
+// and the exit status: 0 for -h, or 2 otherwise.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0, false
+		}
+		return 2, false
+	}
+	return 0, true
+}
+
+func (c *cli) parseTemplate(raw string) (*uritemplate.Template, bool) {
+	tmpl, err := uritemplate.New(raw)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return nil, false
+	}
+	return tmpl, true
+}
+
+// valuesFlag collects variables given as name=value.
+type valuesFlag struct {
+	values uritemplate.Values
+	t      uritemplate.ValueType
+}
+
+func (f *valuesFlag) String() string {
+	return ""
+}
+
+func (f *valuesFlag) Set(s string) error {
+	eq := strings.IndexByte(s, '=')
+	if eq < 0 {
+		return fmt.Errorf("%q is not in the form of name=value", s)
+	}
+	name, value := s[:eq], s[eq+1:]
+	switch f.t {
+	case uritemplate.ValueTypeString:
+		f.values.Set(name, uritemplate.String(value))
+	case uritemplate.ValueTypeList:
+		f.values.Set(name, uritemplate.List(strings.Split(value, ",")...))
+	case uritemplate.ValueTypeKV:
+		kv := strings.Split(value, ",")
+		if len(kv)%2 != 0 {
+			return fmt.Errorf("%q does not have even number of keys and values", s)
+		}
+		f.values.Set(name, uritemplate.KV(kv...))
+	}
+	return nil
+}
+
+func (c *cli) runExpand(args []string) int {
+	values := uritemplate.Values{}
+	fs := c.newFlagSet("expand", "template")
+	fs.Var(&valuesFlag{values, uritemplate.ValueTypeString}, "var", "set a string `name=value`")
+	fs.Var(&valuesFlag{values, uritemplate.ValueTypeList}, "list", "set a list `name=a,b,...`")
+	fs.Var(&valuesFlag{values, uritemplate.ValueTypeKV}, "kv", "set an associative array `name=k1,v1,...`")
+	jsonFile := fs.String("json", "", "read variables from a JSON object in `file` (- for stdin)")
+	env := fs.Bool("env", false, "read variables not set otherwise from environment variables")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	tmpl, ok := c.parseTemplate(fs.Arg(0))
+	if !ok {
+		return 1
+	}
+	if *jsonFile != "" {
+		jsonValues, err := c.readJSONValues(*jsonFile)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return 1
+		}
+		for name, value := range jsonValues {
+			if _, ok := values[name]; !ok {
+				values.Set(name, value)
+			}
+		}
+	}
+	if *env {
+		for _, name := range tmpl.Varnames() {
+			if _, ok := values[name]; ok {
+				continue
+			}
+			if value, ok := c.lookupEnv(name); ok {
+				values.Set(name, uritemplate.String(value))
+			}
+		}
+	}
+
+	s, err := tmpl.Expand(values)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 1
+	}
+	fmt.Fprintln(c.stdout, s)
+	return 0
+}
+
+func (c *cli) readJSONValues(name string) (uritemplate.Values, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(c.stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	values := uritemplate.Values{}
+	for varname, msg := range raw {
+		value, err := decodeJSONValue(msg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", name, varname, err)
+		}
+		if value.Valid() {
+			values.Set(varname, value)
+		}
+	}
+	return values, nil
+}
+
+// decodeJSONValue converts a JSON value to uritemplate.Value. Objects
+// become associative arrays in the order of their members.
+func decodeJSONValue(msg json.RawMessage) (uritemplate.Value, error) {
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return uritemplate.Value{}, err
+	}
+	if delim, ok := tok.(json.Delim); ok {
+		var v []string
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return uritemplate.Value{}, err
+			}
+			s, ok := jsonScalar(tok)
+			if !ok {
+				return uritemplate.Value{}, fmt.Errorf("nested %v is not supported", tok)
+			}
+			v = append(v, s)
+		}
+		if delim == '{' {
+			return uritemplate.KV(v...), nil
+		}
+		return uritemplate.List(v...), nil
+	}
+	if tok == nil {
+		return uritemplate.Value{}, nil
+	}
+	s, _ := jsonScalar(tok)
+	return uritemplate.String(s), nil
+}
+
+func jsonScalar(tok json.Token) (string, bool) {
+	switch tok := tok.(type) {
+	case string:
+		return tok, true
+	case json.Number:
+		return tok.String(), true
+	case bool:
+		return fmt.Sprint(tok), true
+	}
+	return "", false
+}
+
+// kvFlag collects names of variables that are associative arrays.
+type kvFlag map[string]uritemplate.ValueType
+
+func (f kvFlag) String() string {
+	return ""
+}
+
+func (f kvFlag) Set(name string) error {
+	f[name] = uritemplate.ValueTypeKV
+	return nil
+}
+
+func (c *cli) runMatch(args []string) int {
+	hints := kvFlag{}
+	fs := c.newFlagSet("match", "template uri")
+	fs.Var(hints, "kv", "match variable `name` as an associative array")
+	unordered := fs.Bool("unordered-query", false, "match trailing query expressions in any order")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	tmpl, ok := c.parseTemplate(fs.Arg(0))
+	if !ok {
+		return 1
+	}
+	values := tmpl.MatchWithOptions(fs.Arg(1), uritemplate.MatchOptions{
+		Hints:          hints,
+		UnorderedQuery: *unordered,
+	})
+	if values == nil {
+		fmt.Fprintln(c.stderr, "uritemplate: no match")
+		return 1
+	}
+	writeJSONValues(c.stdout, values)
+	return 0
+}
+
+// writeJSONValues writes values as a JSON object. Associative arrays are
+// written as objects in the order of their keys.
+func writeJSONValues(w io.Writer, values uritemplate.Values) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeJSONString(&b, name)
+		b.WriteByte(':')
+		switch value := values[name]; value.T {
+		case uritemplate.ValueTypeString:
+			writeJSONString(&b, value.String())
+		case uritemplate.ValueTypeList:
+			b.WriteByte('[')
+			for j, v := range value.V {
+				if j > 0 {
+					b.WriteByte(',')
+				}
+				writeJSONString(&b, v)
+			}
+			b.WriteByte(']')
+		case uritemplate.ValueTypeKV:
+			b.WriteByte('{')
+			for j := 0; j+1 < len(value.V); j += 2 {
+				if j > 0 {
+					b.WriteByte(',')
+				}
+				writeJSONString(&b, value.V[j])
+				b.WriteByte(':')
+				writeJSONString(&b, value.V[j+1])
+			}
+			b.WriteByte('}')
+		}
+	}
+	b.WriteByte('}')
+
+	var out bytes.Buffer
+	json.Indent(&out, b.Bytes(), "", "  ")
+	out.WriteByte('\n')
+	w.Write(out.Bytes())
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
+
+var dialects = map[string]uritemplate.RegexpDialect{
+	"re2":        uritemplate.RegexpRE2,
+	"ecmascript": uritemplate.RegexpECMAScript,
+	"pcre":       uritemplate.RegexpPCRE,
+}
+
+func (c *cli) runRegexp(args []string) int {
+	fs := c.newFlagSet("regexp", "template")
+	named := fs.Bool("named", false, "capture each variable in a named group")
+	dialect := fs.String("dialect", "re2", "print the regexp in `syntax` of re2, ecmascript or pcre")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	d, ok := dialects[*dialect]
+	if !ok {
+		fmt.Fprintf(c.stderr, "uritemplate: unknown dialect %q\n", *dialect)
+		return 2
+	}
+
+	tmpl, ok := c.parseTemplate(fs.Arg(0))
+	if !ok {
+		return 1
+	}
+	if !*named && d == uritemplate.RegexpRE2 {
+		fmt.Fprintln(c.stdout, tmpl.Regexp())
+		return 0
+	}
+	re, _ := tmpl.RegexpString(uritemplate.RegexpOptions{
+		Dialect:     d,
+		NamedGroups: *named,
+	})
+	fmt.Fprintln(c.stdout, re)
+	return 0
+}
+
+func (c *cli) runVars(args []string) int {
+	fs := c.newFlagSet("vars", "template")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	tmpl, ok := c.parseTemplate(fs.Arg(0))
+	if !ok {
+		return 1
+	}
+	for _, name := range tmpl.Varnames() {
+		fmt.Fprintln(c.stdout, name)
+	}
+	return 0
+}
+
+func (c *cli) runLint(args []string) int {
+	fs := c.newFlagSet("lint", "[template ...]")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	templates := fs.Args()
+	if len(templates) == 0 {
+		s := bufio.NewScanner(c.stdin)
+		for s.Scan() {
+			if line := s.Text(); line != "" {
+				templates = append(templates, line)
+			}
+		}
+		if err := s.Err(); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return 1
+		}
+	}
+
+	status := 0
+	for _, raw := range templates {
+		tmpl, err := uritemplate.New(raw)
+		if err != nil {
+			fmt.Fprintln(c.stdout, err)
+			status = 1
+			continue
+		}
+		for _, d := range uritemplate.Lint(tmpl) {
+			fmt.Fprintf(c.stdout, "%s:%s\n", raw, d)
+			if d.Severity > uritemplate.SeverityInfo {
+				status = 1
+			}
+		}
+	}
+	return status
+}
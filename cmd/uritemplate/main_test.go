@@ -0,0 +1,138 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yosida95/uritemplate/v3"
+)
+
+func regexpString(raw string, opts uritemplate.RegexpOptions) string {
+	re, _ := uritemplate.MustNew(raw).RegexpString(opts)
+	return re
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uritemplate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jsonFile := filepath.Join(dir, "vars.json")
+	if err := ioutil.WriteFile(jsonFile, []byte(`{"id": "42", "tags": ["a", "b"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"id": "env", "page": "2"}
+	for _, c := range []struct {
+		args   []string
+		stdin  string
+		stdout string
+		stderr string // a substring of the standard error
+		status int
+	}{
+		{args: nil, stderr: "usage: uritemplate <command>", status: 2},
+		{args: []string{"unknown"}, stderr: `unknown command "unknown"`, status: 2},
+
+		// expand
+		{
+			args:   []string{"expand", "-var", "x=a b", "-list", "l=a,b", "-kv", "k=a,1,b,2", "{x}{/l*}{?k*}"},
+			stdout: "a%20b/a/b?a=1&b=2\n",
+		},
+		{args: []string{"expand", "-var", "x", "{x}"}, stderr: `"x" is not in the form of name=value`, status: 2},
+		{args: []string{"expand", "-kv", "k=a", "{k}"}, stderr: "does not have even number of keys and values", status: 2},
+		{args: []string{"expand", "-unknown", "{x}"}, stderr: "usage: uritemplate expand", status: 2},
+		{args: []string{"expand"}, stderr: "usage: uritemplate expand", status: 2},
+		{args: []string{"expand", "-h"}, stderr: "usage: uritemplate expand", status: 0},
+		{args: []string{"expand", "{x"}, stderr: "incomplete expression", status: 1},
+		{args: []string{"expand", "{x:3}", "-var", "x=abcd"}, stderr: "usage: uritemplate expand", status: 2},
+		{args: []string{"expand", "-list", "x=a,b", "{x:3}"}, stderr: "prefix", status: 1},
+		{
+			args:   []string{"expand", "-json", "-", "{id,n,t,f}{/l*}{?k*}{u}"},
+			stdin:  `{"id": "a", "n": 1.5, "t": true, "f": false, "l": ["p", "q"], "k": {"b": "2", "a": "1"}, "u": null}`,
+			stdout: "a,1.5,true,false/p/q?b=2&a=1\n",
+		},
+		{args: []string{"expand", "-json", jsonFile, "/users/{id}{?tags}"}, stdout: "/users/42?tags=a,b\n"},
+		{args: []string{"expand", "-json", jsonFile, "-var", "id=7", "/users/{id}"}, stdout: "/users/7\n"},
+		{args: []string{"expand", "-json", "-", "{x}"}, stdin: `{"x": `, stderr: "-: ", status: 1},
+		{args: []string{"expand", "-json", "-", "{x}"}, stdin: `{"x": [[1]]}`, stderr: "-: x: nested", status: 1},
+		{args: []string{"expand", "-json", filepath.Join(dir, "missing.json"), "{x}"}, stderr: "missing.json", status: 1},
+		{args: []string{"expand", "-env", "/users/{id}{?page,lang}"}, stdout: "/users/env?page=2\n"},
+		{args: []string{"expand", "-env", "-var", "id=7", "/users/{id}"}, stdout: "/users/7\n"},
+		{args: []string{"expand", "/users/{id}"}, stdout: "/users/\n"},
+
+		// match
+		{args: []string{"match", "/users/{id}", "/users/42"}, stdout: "{\n  \"id\": \"42\"\n}\n"},
+		{args: []string{"match", "/users/{id}", "/posts/42"}, stderr: "no match", status: 1},
+		{args: []string{"match", "/users/{id}"}, stderr: "usage: uritemplate match", status: 2},
+		{args: []string{"match", "{/list*}", "/a/b"}, stdout: "{\n  \"list\": [\n    \"a\",\n    \"b\"\n  ]\n}\n"},
+		{args: []string{"match", "-kv", "keys", "{?keys*}", "?b=2&a=1"}, stdout: "{\n  \"keys\": {\n    \"b\": \"2\",\n    \"a\": \"1\"\n  }\n}\n"},
+		{args: []string{"match", "/s{?q,page}", "/s?page=2&q=x"}, stderr: "no match", status: 1},
+		{args: []string{"match", "-unordered-query", "/s{?q,page}", "/s?page=2&q=x"}, stdout: "{\n  \"page\": \"2\",\n  \"q\": \"x\"\n}\n"},
+
+		// regexp
+		{args: []string{"regexp", "/{id}"}, stdout: uritemplate.MustNew("/{id}").Regexp().String() + "\n"},
+		{
+			args:   []string{"regexp", "-named", "/{id}"},
+			stdout: regexpString("/{id}", uritemplate.RegexpOptions{NamedGroups: true}) + "\n",
+		},
+		{
+			args:   []string{"regexp", "-dialect", "ecmascript", "/{id}"},
+			stdout: regexpString("/{id}", uritemplate.RegexpOptions{Dialect: uritemplate.RegexpECMAScript}) + "\n",
+		},
+		{
+			args:   []string{"regexp", "-dialect", "pcre", "-named", "/{id}"},
+			stdout: regexpString("/{id}", uritemplate.RegexpOptions{Dialect: uritemplate.RegexpPCRE, NamedGroups: true}) + "\n",
+		},
+		{args: []string{"regexp", "-dialect", "posix", "/{id}"}, stderr: `unknown dialect "posix"`, status: 2},
+		{args: []string{"regexp", "{"}, stderr: "incomplete expression", status: 1},
+
+		// vars
+		{args: []string{"vars", "{a}/{b}{?a,c}"}, stdout: "a\nb\nc\n"},
+		{args: []string{"vars"}, stderr: "usage: uritemplate vars", status: 2},
+
+		// lint
+		{args: []string{"lint", "/a/{b}"}},
+		{args: []string{"lint", "/{t:1}/{t}"}, stdout: "/{t:1}/{t}:9: info: t is used both as a prefix and in full\n"},
+		{args: []string{"lint", "/a/{b}", "/{a}{b}"}, stdout: "/{a}{b}:5: warning: {b} immediately follows {a}; Match cannot tell where one ends\n", status: 1},
+		{args: []string{"lint", "{"}, stdout: "uritemplate:2:incomplete expression: {\n", status: 1},
+		{
+			args:   []string{"lint"},
+			stdin:  "/a/{b}\n\n/{a}{b}\n",
+			stdout: "/{a}{b}:5: warning: {b} immediately follows {a}; Match cannot tell where one ends\n",
+			status: 1,
+		},
+		{args: []string{"lint"}, stdin: "/a/{b}\n"},
+	} {
+		var stdout, stderr bytes.Buffer
+		cmd := &cli{
+			stdin:  strings.NewReader(c.stdin),
+			stdout: &stdout,
+			stderr: &stderr,
+			lookupEnv: func(name string) (string, bool) {
+				v, ok := env[name]
+				return v, ok
+			},
+		}
+		status := cmd.run(c.args)
+		if status != c.status {
+			t.Errorf("on %q: expected status %d, but got %d: %s", c.args, c.status, status, stderr.String())
+		}
+		if actual := stdout.String(); actual != c.stdout {
+			t.Errorf("on %q: expected %q in the standard output, but got %q", c.args, c.stdout, actual)
+		}
+		if actual := stderr.String(); !strings.Contains(actual, c.stderr) || (c.stderr == "" && actual != "") {
+			t.Errorf("on %q: expected %q in the standard error, but got %q", c.args, c.stderr, actual)
+		}
+	}
+}
@@ -12,7 +12,13 @@ import (
 )
 
 type compiler struct {
-	prog *prog
+	prog           *prog
+	hints          map[string]ValueType
+	unorderedQuery bool
+
+	// strict makes the program match only what Expand produces, without
+	// the separators and commas Match tolerates.
+	strict bool
 }
 
 func (c *compiler) init() {
@@ -64,19 +70,60 @@ func (c *compiler) compileString(str string) {
 }
 
 func (c *compiler) compileRuneClass(rc runeClass, maxlen int) {
+	var skips []uint32
 	for i := 0; i < maxlen; i++ {
 		if i > 0 {
-			c.opWithAddrDelta(opSplit, 7)
+			skips = append(skips, c.op(opSplit))
+		}
+		c.compileChar(rc)
+	}
+	for _, addr := range skips {
+		c.prog.op[addr].i = uint32(len(c.prog.op))
+	}
+}
+
+// pctSeqs are the first hex digits of the lead triplets of UTF-8 sequences
+// and the counts of the continuation triplets that follow them.
+var pctSeqs = []struct {
+	lead runeClass
+	cont int
+}{
+	{runeClassPctASCII, 0},
+	{runeClassPctLead2, 1},
+	{runeClassPctLead3, 2},
+	{runeClassPctLead4, 3},
+}
+
+// compileChar compiles a character, which is a raw rune or the
+// pct-encoded triplets of a UTF-8 sequence.
+func (c *compiler) compileChar(rc runeClass) {
+	var jmps []uint32
+
+	split := c.op(opSplit)
+	c.opWithRuneClass(opRuneClass, rc) // raw rune
+	jmps = append(jmps, c.op(opJmp))
+	for i, seq := range pctSeqs {
+		c.prog.op[split].i = uint32(len(c.prog.op))
+		if i < len(pctSeqs)-1 {
+			split = c.op(opSplit)
+		}
+		c.compileTriplet(seq.lead)
+		for j := 0; j < seq.cont; j++ {
+			c.compileTriplet(runeClassPctCont)
 		}
-		c.opWithAddrDelta(opSplit, 3)                 // raw rune or pct-encoded
-		c.opWithRuneClass(opRuneClass, rc)            // raw rune
-		c.opWithAddrDelta(opJmp, 4)                   //
-		c.opWithRune(opRune, '%')                     // pct-encoded
-		c.opWithRuneClass(opRuneClass, runeClassPctE) //
-		c.opWithRuneClass(opRuneClass, runeClassPctE) //
+		jmps = append(jmps, c.op(opJmp))
+	}
+	for _, addr := range jmps {
+		c.prog.op[addr].i = uint32(len(c.prog.op))
 	}
 }
 
+func (c *compiler) compileTriplet(lead runeClass) {
+	c.opWithRune(opRune, '%')
+	c.opWithRuneClass(opRuneClass, lead)
+	c.opWithRuneClass(opRuneClass, runeClassPctE)
+}
+
 func (c *compiler) compileRuneClassInfinite(rc runeClass) {
 	start := c.opWithAddrDelta(opSplit, 3)        // raw rune or pct-encoded
 	c.opWithRuneClass(opRuneClass, rc)            // raw rune
@@ -88,19 +135,30 @@ func (c *compiler) compileRuneClassInfinite(rc runeClass) {
 	c.opWithAddr(opJmp, start)                    //
 }
 
-func (c *compiler) compileVarspecValue(spec varspec, expr *expression) {
-	var specname string
+func specName(spec Varspec) string {
 	if spec.maxlen > 0 {
-		specname = fmt.Sprintf("%s:%d", spec.name, spec.maxlen)
-	} else {
-		specname = spec.name
+		return fmt.Sprintf("%s:%d", spec.name, spec.maxlen)
 	}
+	return spec.name
+}
+
+func (c *compiler) compileVarspecValue(spec Varspec, expr *expression) {
+	c.compileVarspecCapture(spec, expr, true)
+}
+
+// compileVarspecCapture compiles a capture of a value of spec, which may
+// be empty if empty is true.
+func (c *compiler) compileVarspecCapture(spec Varspec, expr *expression, empty bool) {
+	specname := specName(spec)
 
 	c.prog.numCap++
 
 	c.opWithName(opCapStart, specname)
 
-	split := c.op(opSplit)
+	var split uint32
+	if empty {
+		split = c.op(opSplit)
+	}
 	if spec.maxlen > 0 {
 		c.compileRuneClass(expr.allow, spec.maxlen)
 	} else {
@@ -108,10 +166,167 @@ func (c *compiler) compileVarspecValue(spec varspec, expr *expression) {
 	}
 
 	capEnd := c.opWithName(opCapEnd, specname)
-	c.prog.op[split].i = capEnd
+	if empty {
+		c.prog.op[split].i = capEnd
+	}
+}
+
+func (c *compiler) compileEmptyCapture(spec Varspec) {
+	specname := specName(spec)
+	c.opWithName(opCapStart, specname)
+	c.opWithName(opCapEnd, specname)
+}
+
+// compileVarspecKV compiles spec so that both keys and values of an
+// associative array are captured in turn.
+func (c *compiler) compileVarspecKV(spec Varspec, expr *expression) {
+	switch {
+	case expr.named && spec.explode:
+		start := uint32(len(c.prog.op))
+		c.compileVarspecValue(spec, expr) // key
+
+		split1 := c.op(opSplit)
+		c.opWithRune(opRune, '=')
+		c.compileVarspecValue(spec, expr) // value
+		jmp1 := c.op(opJmp)
+
+		c.prog.op[split1].i = uint32(len(c.prog.op))
+		c.compileString(expr.ifemp)
+		c.compileEmptyCapture(spec)
+
+		c.prog.op[jmp1].i = uint32(len(c.prog.op))
+		split2 := c.op(opSplit)
+		c.compileString(expr.sep)
+		c.opWithAddr(opJmp, start)
+
+		c.prog.op[split2].i = uint32(len(c.prog.op))
+
+	case spec.explode:
+		start := uint32(len(c.prog.op))
+		c.compileVarspecValue(spec, expr) // key
+		c.opWithRune(opRune, '=')
+		c.compileVarspecValue(spec, expr) // value
+
+		split1 := c.op(opSplit)
+		c.compileString(expr.sep)
+		c.opWithAddr(opJmp, start)
+
+		c.prog.op[split1].i = uint32(len(c.prog.op))
+
+	default:
+		if expr.named {
+			c.compileString(spec.name)
+			c.opWithRune(opRune, '=')
+		}
+
+		start := uint32(len(c.prog.op))
+		c.compileVarspecValue(spec, expr) // key
+		c.opWithRune(opRune, ',')
+		c.compileVarspecValue(spec, expr) // value
+
+		split1 := c.op(opSplit)
+		c.opWithRune(opRune, ',')
+		c.opWithAddr(opJmp, start)
+
+		c.prog.op[split1].i = uint32(len(c.prog.op))
+	}
 }
 
-func (c *compiler) compileVarspec(spec varspec, expr *expression) {
+// compileVarspecStrict compiles spec in the forms Expand produces for a
+// defined value.
+func (c *compiler) compileVarspecStrict(spec Varspec, expr *expression) {
+	var sep string
+	switch {
+	case expr.named && spec.explode:
+		start := uint32(len(c.prog.op))
+		c.compileString(spec.name)
+		c.compileNamedValue(spec, expr)
+		split1 := c.op(opSplit)
+		c.compileString(expr.sep)
+		c.opWithAddr(opJmp, start)
+		c.prog.op[split1].i = uint32(len(c.prog.op))
+		return
+	case expr.named:
+		c.compileString(spec.name)
+		c.compileNamedValue(spec, expr)
+		return
+	case spec.maxlen > 0:
+		c.compileVarspecValue(spec, expr)
+		return
+	case spec.explode:
+		sep = expr.sep
+	default:
+		sep = ","
+	}
+
+	start := uint32(len(c.prog.op))
+	c.compileVarspecValue(spec, expr)
+	split1 := c.op(opSplit)
+	c.compileString(sep)
+	c.opWithAddr(opJmp, start)
+	c.prog.op[split1].i = uint32(len(c.prog.op))
+}
+
+// compileNamedValue compiles what follows the name of spec in a named
+// expression: "=" and a non-empty string, "=" and a list of two or more
+// elements unless spec has the explode modifier, or ifemp for an empty
+// string.
+func (c *compiler) compileNamedValue(spec Varspec, expr *expression) {
+	split1 := c.op(opSplit)
+	c.opWithRune(opRune, '=')
+	if spec.maxlen > 0 || spec.explode {
+		c.compileVarspecCapture(spec, expr, false)
+	} else {
+		split2 := c.op(opSplit)
+		c.compileVarspecCapture(spec, expr, false)
+		jmp1 := c.op(opJmp)
+
+		c.prog.op[split2].i = uint32(len(c.prog.op))
+		c.compileVarspecValue(spec, expr)
+		loop := c.opWithRune(opRune, ',')
+		c.compileVarspecValue(spec, expr)
+		split3 := c.op(opSplit)
+		c.opWithAddr(opJmp, loop)
+
+		c.prog.op[split3].i = uint32(len(c.prog.op))
+		c.prog.op[jmp1].i = uint32(len(c.prog.op))
+	}
+	jmp2 := c.op(opJmp)
+
+	c.prog.op[split1].i = uint32(len(c.prog.op))
+	c.compileString(expr.ifemp)
+	c.compileEmptyCapture(spec)
+
+	c.prog.op[jmp2].i = uint32(len(c.prog.op))
+}
+
+func (c *compiler) compileVarspec(spec Varspec, expr *expression) {
+	if spec.maxlen == 0 && c.hints[spec.name] == ValueTypeKV {
+		c.compileVarspecKV(spec, expr)
+		return
+	}
+	if c.strict {
+		c.compileVarspecStrict(spec, expr)
+		return
+	}
+	if spec.explode {
+		// as a list, or as an associative array under kvCapName
+		split1 := c.op(opSplit)
+		c.compileVarspecList(spec, expr)
+		jmp1 := c.op(opJmp)
+		c.prog.op[split1].i = uint32(len(c.prog.op))
+		kv := spec
+		kv.name = kvCapName(spec.name)
+		c.compileVarspecKV(kv, expr)
+		c.prog.op[jmp1].i = uint32(len(c.prog.op))
+		return
+	}
+	c.compileVarspecList(spec, expr)
+}
+
+// compileVarspecList compiles spec so that its value is captured as a
+// string or the elements of a list.
+func (c *compiler) compileVarspecList(spec Varspec, expr *expression) {
 	switch {
 	case expr.named && spec.explode:
 		split1 := c.op(opSplit)
@@ -178,10 +393,45 @@ func (c *compiler) compileVarspec(spec varspec, expr *expression) {
 	}
 }
 
+// compileExpressionStrict compiles expr so that the first defined varspec
+// follows expr.first, and each other one expr.sep.
+func (c *compiler) compileExpressionStrict(expr *expression) {
+	var jmps []uint32
+
+	split1 := c.op(opSplit)
+	c.compileString(expr.first)
+	for i, size := 0, len(expr.vars); i < size; i++ {
+		var split2 uint32
+		if i < size-1 {
+			split2 = c.op(opSplit)
+		}
+		c.compileVarspec(expr.vars[i], expr)
+		for _, spec := range expr.vars[i+1:] {
+			split3 := c.op(opSplit)
+			c.compileString(expr.sep)
+			c.compileVarspec(spec, expr)
+			c.prog.op[split3].i = uint32(len(c.prog.op))
+		}
+		jmps = append(jmps, c.op(opJmp))
+		if i < size-1 {
+			c.prog.op[split2].i = uint32(len(c.prog.op))
+		}
+	}
+
+	c.prog.op[split1].i = uint32(len(c.prog.op))
+	for _, addr := range jmps {
+		c.prog.op[addr].i = uint32(len(c.prog.op))
+	}
+}
+
 func (c *compiler) compileExpression(expr *expression) {
 	if len(expr.vars) < 1 {
 		return
 	}
+	if c.strict {
+		c.compileExpressionStrict(expr)
+		return
+	}
 
 	split1 := c.op(opSplit)
 	c.compileString(expr.first)
@@ -206,10 +456,27 @@ func (c *compiler) compileLiterals(lt literals) {
 	c.compileString(string(lt))
 }
 
+// compileQuery compiles a capture of the rest of the input, which
+// matchQuery handles in place of the query expressions.
+func (c *compiler) compileQuery() {
+	c.prog.numCap++
+	c.opWithName(opCapStart, queryCapName)
+	split := c.op(opSplit)
+	c.compileRuneClassInfinite(runeClassUR)
+	capEnd := c.opWithName(opCapEnd, queryCapName)
+	c.prog.op[split].i = capEnd
+}
+
 func (c *compiler) compile(tmpl *Template) {
+	exprs := tmpl.exprs
+	var query []*expression
+	if c.unorderedQuery {
+		exprs, query = splitQueryExprs(exprs)
+	}
+
 	c.op(opLineBegin)
-	for i := range tmpl.exprs {
-		expr := tmpl.exprs[i]
+	for i := range exprs {
+		expr := exprs[i]
 		switch expr := expr.(type) {
 		default:
 			panic("unhandled expression")
@@ -219,6 +486,9 @@ func (c *compiler) compile(tmpl *Template) {
 			c.compileLiterals(expr)
 		}
 	}
+	if len(query) > 0 {
+		c.compileQuery()
+	}
 	c.op(opLineEnd)
 	c.op(opEnd)
 }
@@ -0,0 +1,183 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// conformanceGroup is a group of test cases in the format of the
+// uritemplate-test suite.
+type conformanceGroup struct {
+	Level     int                        `json:"level"`
+	Variables map[string]json.RawMessage `json:"variables"`
+	Testcases [][2]json.RawMessage       `json:"testcases"`
+}
+
+// conformanceValue converts a variable of the suite to Value. Members of
+// an object keep their order so that KV is deterministic.
+func conformanceValue(raw json.RawMessage) (Value, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return Value{}, err
+	}
+	switch tok := tok.(type) {
+	case string:
+		return String(tok), nil
+	case json.Number:
+		return String(tok.String()), nil
+	case json.Delim:
+		var v []string
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return Value{}, err
+			}
+			switch tok := tok.(type) {
+			case string:
+				v = append(v, tok)
+			case json.Number:
+				v = append(v, tok.String())
+			default:
+				return Value{}, fmt.Errorf("unexpected token %v", tok)
+			}
+		}
+		if tok == '{' {
+			return KV(v...), nil
+		}
+		return List(v...), nil
+	case nil:
+		return Value{}, nil
+	}
+	return Value{}, fmt.Errorf("unexpected token %v", tok)
+}
+
+// conformanceExpected returns the acceptable expansions of a test case,
+// or nil if the test case must fail.
+func conformanceExpected(raw json.RawMessage) ([]string, error) {
+	var expected interface{}
+	if err := json.Unmarshal(raw, &expected); err != nil {
+		return nil, err
+	}
+	switch expected := expected.(type) {
+	case string:
+		return []string{expected}, nil
+	case []interface{}:
+		ret := make([]string, len(expected))
+		for i := range expected {
+			s, ok := expected[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected expansion %v", expected[i])
+			}
+			ret[i] = s
+		}
+		return ret, nil
+	case bool:
+		if !expected {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected expansion %v", expected)
+}
+
+// conformanceDir is where the uritemplate-test suite is vendored.
+var conformanceDir = filepath.Join("testdata", "uritemplate-test")
+
+func TestConformance(t *testing.T) {
+	if _, err := os.Stat(conformanceDir); err != nil {
+		t.Fatalf("%s is not vendored; see testdata/README.rst: %v", conformanceDir, err)
+	}
+	for _, name := range []string{
+		"spec-examples.json",
+		"extended-tests.json",
+		"negative-tests.json",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join(conformanceDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var groups map[string]conformanceGroup
+			if err := json.Unmarshal(data, &groups); err != nil {
+				t.Fatal(err)
+			}
+			for title, group := range groups {
+				t.Run(title, func(t *testing.T) {
+					testConformanceGroup(t, group)
+				})
+			}
+		})
+	}
+}
+
+func testConformanceGroup(t *testing.T, group conformanceGroup) {
+	vars := Values{}
+	hints := make(map[string]ValueType)
+	for name, raw := range group.Variables {
+		v, err := conformanceValue(raw)
+		if err != nil {
+			t.Fatalf("variable %q: %v", name, err)
+		}
+		vars.Set(name, v)
+		hints[name] = v.T
+	}
+
+	for _, c := range group.Testcases {
+		var raw string
+		if err := json.Unmarshal(c[0], &raw); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := conformanceExpected(c[1])
+		if err != nil {
+			t.Fatalf("on %q: %v", raw, err)
+		}
+
+		tmpl, err := New(raw)
+		if expected == nil {
+			if err == nil {
+				if s, err := tmpl.Expand(vars); err == nil {
+					t.Errorf("on %q: expected error, but got %q", raw, s)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("on %q: unexpected error: %v", raw, err)
+			continue
+		}
+
+		s, err := tmpl.Expand(vars)
+		if err != nil {
+			t.Errorf("on %q: unexpected error: %v", raw, err)
+			continue
+		}
+		if !containsString(expected, s) {
+			t.Errorf("on %q: unexpected expansion %q, expected one of %q", raw, s, expected)
+			continue
+		}
+		if tmpl.MatchWithHints(s, hints) == nil {
+			t.Errorf("on %q: failed to match %q", raw, s)
+		}
+	}
+}
+
+func containsString(a []string, s string) bool {
+	for i := range a {
+		if a[i] == s {
+			return true
+		}
+	}
+	return false
+}
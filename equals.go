@@ -6,48 +6,134 @@
 
 package uritemplate
 
+import (
+	"strings"
+)
+
+// CompareFlags controls how Equals compares templates.
 type CompareFlags uint8
 
 const (
+	// CompareVarname compares variable names, which are ignored otherwise.
 	CompareVarname CompareFlags = 1 << iota
+	// CompareIgnorePctCase ignores the case of pct-encoded triplets in
+	// literals, such as %2f and %2F.
+	CompareIgnorePctCase
+	// CompareNormalizeLiterals compares literals in the form Normalize
+	// returns, where triplets of unreserved characters are decoded. It
+	// implies CompareIgnorePctCase.
+	CompareNormalizeLiterals
+	// CompareIgnoreMaxlen ignores prefix modifiers.
+	CompareIgnoreMaxlen
+	// CompareSemantic regards templates as equal if they expand to the
+	// same URIs for any values, such as {/a}{/b} and {/a,b}.
+	CompareSemantic
+	// CompareRenaming regards templates as equal if variables of one are
+	// renamed one-to-one to those of the other, so /{a}/{a} and /{a}/{b}
+	// are not equal.
+	CompareRenaming
 )
 
 // Equals reports whether or not two URI Templates t1 and t2 are equivalent.
 func Equals(t1 *Template, t2 *Template, flags CompareFlags) bool {
-	if len(t1.exprs) != len(t2.exprs) {
-		return false
+	_, ok := equals(t1, t2, flags)
+	return ok
+}
+
+// Renaming is like Equals with CompareRenaming, but also returns the
+// renaming that maps the variable names of t1 to those of t2.
+func Renaming(t1 *Template, t2 *Template, flags CompareFlags) (map[string]string, bool) {
+	return equals(t1, t2, flags|CompareRenaming)
+}
+
+func equals(t1 *Template, t2 *Template, flags CompareFlags) (map[string]string, bool) {
+	var renaming, inverse map[string]string
+	if flags&CompareRenaming != 0 {
+		renaming = make(map[string]string)
+		inverse = make(map[string]string)
+	}
+
+	exprs1, exprs2 := compareExprs(t1, flags), compareExprs(t2, flags)
+	if len(exprs1) != len(exprs2) {
+		return nil, false
 	}
-	for i := 0; i < len(t1.exprs); i++ {
-		switch t1 := t1.exprs[i].(type) {
+	for i := 0; i < len(exprs1); i++ {
+		switch t1 := exprs1[i].(type) {
 		case literals:
-			t2, ok := t2.exprs[i].(literals)
+			t2, ok := exprs2[i].(literals)
 			if !ok {
-				return false
+				return nil, false
 			}
 			if t1 != t2 {
-				return false
+				return nil, false
 			}
 		case *expression:
-			t2, ok := t2.exprs[i].(*expression)
+			t2, ok := exprs2[i].(*expression)
 			if !ok {
-				return false
+				return nil, false
 			}
 			if t1.op != t2.op || len(t1.vars) != len(t2.vars) {
-				return false
+				return nil, false
 			}
 			for n := 0; n < len(t1.vars); n++ {
 				v1 := t1.vars[n]
 				v2 := t2.vars[n]
 				if flags&CompareVarname == CompareVarname && v1.name != v2.name {
-					return false
+					return nil, false
+				}
+				if renaming != nil {
+					if name, ok := renaming[v1.name]; ok && name != v2.name {
+						return nil, false
+					}
+					if name, ok := inverse[v2.name]; ok && name != v1.name {
+						return nil, false
+					}
+					renaming[v1.name] = v2.name
+					inverse[v2.name] = v1.name
+				}
+				if flags&CompareIgnoreMaxlen == 0 && v1.maxlen != v2.maxlen {
+					return nil, false
 				}
-				if v1.maxlen != v2.maxlen || v1.explode != v2.explode {
-					return false
+				if v1.explode != v2.explode {
+					return nil, false
 				}
 			}
 		default:
 			panic("unhandled case")
 		}
 	}
-	return true
+	return renaming, true
+}
+
+// compareExprs returns the parts of t to be compared by Equals with flags.
+func compareExprs(t *Template, flags CompareFlags) []template {
+	var exprs []template
+	for _, expr := range t.exprs {
+		switch expr := expr.(type) {
+		case literals:
+			var b strings.Builder
+			switch {
+			case flags&CompareNormalizeLiterals != 0:
+				normalizeLiterals(&b, string(expr))
+			case flags&CompareIgnorePctCase != 0:
+				upperTriplets(&b, string(expr))
+			default:
+				b.WriteString(string(expr))
+			}
+			exprs = append(exprs, literals(b.String()))
+		case *expression:
+			// Expressions whose first character is the separator expand
+			// to the same as the ones split into each varspec.
+			if flags&CompareSemantic == 0 || expr.first != expr.sep {
+				exprs = append(exprs, expr)
+				continue
+			}
+			for _, spec := range expr.vars {
+				split := *expr
+				split.vars = []Varspec{spec}
+				exprs = append(exprs, &split)
+			}
+		}
+	}
+	return exprs
 }
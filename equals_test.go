@@ -1,6 +1,10 @@
 package uritemplate
 
-import "testing"
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
 
 var testEqualsCases = []struct {
 	t1     string
@@ -12,6 +16,24 @@ var testEqualsCases = []struct {
 	{"http://example.com/{foo}", "http://example.com/{bar}", 0, true},
 	{"http://example.com/{foo}", "http://example.com/{bar}", CompareVarname, false},
 	{"http://example.com/{foo:1}", "http://example.com/{foo:2}", 0, false},
+	{"http://example.com/{foo:1}", "http://example.com/{foo:2}", CompareIgnoreMaxlen, true},
+	{"http://example.com/{foo:1}", "http://example.com/{foo}", CompareIgnoreMaxlen, true},
+	{"http://example.com/{foo}", "http://example.com/{foo*}", CompareIgnoreMaxlen, false},
+	{"/a%2fb/{foo}", "/a%2Fb/{foo}", 0, false},
+	{"/a%2fb/{foo}", "/a%2Fb/{foo}", CompareIgnorePctCase, true},
+	{"/%7Euser/{foo}", "/~user/{foo}", CompareIgnorePctCase, false},
+	{"/%7Euser/{foo}", "/~user/{foo}", CompareNormalizeLiterals, true},
+	{"/a%2fb/{foo}", "/a%2Fb/{foo}", CompareNormalizeLiterals, true},
+	{"/a%2fb/{foo}", "/a/b/{foo}", CompareNormalizeLiterals, false},
+	{"{/a}{/b}", "{/a,b}", 0, false},
+	{"{/a}{/b}", "{/a,b}", CompareSemantic, true},
+	{"{/a}{/b}", "{/b,a}", CompareSemantic | CompareVarname, false},
+	{"{;a}{;b,c}", "{;a,b}{;c}", CompareSemantic, true},
+	{"{.a}{.b}", "{.a,b}", CompareSemantic, true},
+	{"{&a}{&b}", "{&a,b}", CompareSemantic, true},
+	{"{?a}{&b}", "{?a,b}", CompareSemantic, false},
+	{"{a}{b}", "{a,b}", CompareSemantic, false},
+	{"{/a}/{b}", "{/a,b}", CompareSemantic, false},
 }
 
 func TestEquals(t *testing.T) {
@@ -21,3 +43,41 @@ func TestEquals(t *testing.T) {
 		}
 	}
 }
+
+func ExampleRenaming() {
+	renaming, ok := Renaming(MustNew("/users/{id}/posts/{post}"), MustNew("/users/{uid}/posts/{pid}"), 0)
+	fmt.Println(ok, renaming["id"], renaming["post"])
+
+	_, ok = Renaming(MustNew("/{a}/{a}"), MustNew("/{a}/{b}"), 0)
+	fmt.Println(ok)
+
+	// Output:
+	// true uid pid
+	// false
+}
+
+func TestRenaming(t *testing.T) {
+	for _, c := range []struct {
+		t1       string
+		t2       string
+		flags    CompareFlags
+		expected map[string]string
+	}{
+		{"/{a}/{b}", "/{x}/{y}", 0, map[string]string{"a": "x", "b": "y"}},
+		{"/{a}/{a}", "/{x}/{x}", 0, map[string]string{"a": "x"}},
+		{"/{a}/{a}", "/{x}/{y}", 0, nil},
+		{"/{a}/{b}", "/{x}/{x}", 0, nil},
+		{"/{a}/{b}", "/{a}/{b}", CompareVarname, map[string]string{"a": "a", "b": "b"}},
+		{"/{a}/{b}", "/{b}/{a}", CompareVarname, nil},
+		{"{/a,b}{?a}", "{/x}{/y}{?x}", CompareSemantic, map[string]string{"a": "x", "b": "y"}},
+		{"/{a:1}/{a}", "/{x}/{x}", CompareIgnoreMaxlen, map[string]string{"a": "x"}},
+	} {
+		actual, ok := Renaming(MustNew(c.t1), MustNew(c.t2), c.flags)
+		if ok != (c.expected != nil) || ok && !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("on %q and %q: expected %v, but got %v (%t)", c.t1, c.t2, c.expected, actual, ok)
+		}
+		if Equals(MustNew(c.t1), MustNew(c.t2), c.flags|CompareRenaming) != ok {
+			t.Errorf("on %q and %q: Equals disagrees with Renaming", c.t1, c.t2)
+		}
+	}
+}
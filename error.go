@@ -7,10 +7,97 @@
 package uritemplate
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"unicode/utf8"
 )
 
-func errorf(pos int, format string, a ...interface{}) error {
-	msg := fmt.Sprintf(format, a...)
-	return fmt.Errorf("uritemplate:%d:%s", pos, msg)
+// Kinds of errors reported by ParseError and ExpandError. Use errors.Is to
+// test an error against them.
+var (
+	ErrIncompleteExpression  = errors.New("incomplete expression")
+	ErrInvalidUTF8           = errors.New("invalid UTF-8 sequence")
+	ErrUnacceptableCharacter = errors.New("unacceptable character (hint: use %XX encoding)")
+	ErrReservedOperator      = errors.New("unimplemented operator (op-reserved)")
+	ErrInvalidModifier       = errors.New("unrecognized value modifier")
+	ErrInvalidVarname        = errors.New("unacceptable variable name")
+	ErrInvalidMaxlen         = errors.New("max-length must be (0, 9999]")
+	ErrInvalidPctEncoded     = errors.New("incomplete pct-encoded")
+	ErrInvalidEncoding       = errors.New("invalid encoding")
+	ErrPrefixComposite       = errors.New("prefix modifier applied to composite value")
+	ErrPartialExpansion      = errors.New("expansion depends on undefined variables")
+)
+
+// Kinds of errors reported by RouteError.
+var (
+	ErrUnknownRoute    = errors.New("unknown route")
+	ErrMissingVariable = errors.New("missing variable")
+	ErrURLMismatch     = errors.New("URL does not match the template")
+)
+
+// ParseError describes a problem found while parsing a URI Template.
+type ParseError struct {
+	Template string // the template being parsed
+	Offset   int    // byte offset of the problem in Template
+	Column   int    // 1-based column of the problem in runes
+
+	// ExprPos and ExprEnd are the byte offsets of the expression
+	// containing the problem, or -1 if the problem is in literals.
+	// ExprEnd is the end of Template for an unterminated expression.
+	ExprPos int
+	ExprEnd int
+
+	Err error // one of the Err* kinds
+}
+
+func newParseError(raw string, offset int, exprPos int, err error) *ParseError {
+	e := &ParseError{
+		Template: raw,
+		Offset:   offset,
+		Column:   utf8.RuneCountInString(raw[:offset]) + 1,
+		ExprPos:  -1,
+		ExprEnd:  -1,
+		Err:      err,
+	}
+	if exprPos >= 0 {
+		e.ExprPos = exprPos
+		if end := strings.IndexByte(raw[offset:], '}'); end >= 0 {
+			e.ExprEnd = offset + end + 1
+		} else {
+			e.ExprEnd = len(raw)
+		}
+	}
+	return e
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("uritemplate:%d:%s: %s", e.Column, e.Err, e.Template)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ExpandError describes a problem found while expanding a URI Template.
+type ExpandError struct {
+	// Varname is the variable being expanded, or the comma-separated
+	// undefined variables for ErrPartialExpansion.
+	Varname string
+	Offset  int // byte offset of the problem in the value
+
+	// ExprPos and ExprEnd are the byte offsets of the expression being
+	// expanded in the raw template.
+	ExprPos int
+	ExprEnd int
+
+	Err error // one of the Err* kinds
+}
+
+func (e *ExpandError) Error() string {
+	return fmt.Sprintf("uritemplate:%d:%s: %s", e.Offset, e.Err, e.Varname)
+}
+
+func (e *ExpandError) Unwrap() error {
+	return e.Err
 }
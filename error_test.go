@@ -0,0 +1,73 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"errors"
+	"testing"
+)
+
+var testParseErrorCases = []struct {
+	raw     string
+	err     error
+	offset  int
+	column  int
+	exprPos int
+	exprEnd int
+}{
+	{"{fo", ErrIncompleteExpression, 3, 4, 0, 3},
+	{"a b", ErrUnacceptableCharacter, 1, 2, -1, -1},
+	{"{=x}", ErrReservedOperator, 1, 2, 0, 4},
+	{"{x!}", ErrInvalidVarname, 2, 3, 0, 4},
+	{"{x..y}", ErrInvalidVarname, 3, 4, 0, 6},
+	{"{x.}", ErrInvalidVarname, 1, 2, 0, 4},
+	{"{}", ErrInvalidVarname, 1, 2, 0, 2},
+	{"{x:0}", ErrInvalidMaxlen, 3, 4, 0, 5},
+	{"{x:10000}", ErrInvalidMaxlen, 7, 8, 0, 9},
+	{"{x*y}", ErrInvalidModifier, 3, 4, 0, 5},
+	{"%zz", ErrInvalidPctEncoded, 0, 1, -1, -1},
+	{"{x%2}", ErrInvalidPctEncoded, 2, 3, 0, 5},
+	{"ü{x y}{z}", ErrInvalidVarname, 4, 4, 2, 7},
+	{"a\xff", ErrInvalidUTF8, 1, 2, -1, -1},
+}
+
+func TestParseError(t *testing.T) {
+	for _, c := range testParseErrorCases {
+		_, err := New(c.raw)
+		if !errors.Is(err, c.err) {
+			t.Errorf("on %q: expected %v, got %v", c.raw, c.err, err)
+			continue
+		}
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("on %q: expected *ParseError, got %#v", c.raw, err)
+			continue
+		}
+		if perr.Offset != c.offset || perr.Column != c.column {
+			t.Errorf("on %q: expected offset %d column %d, got %d %d", c.raw, c.offset, c.column, perr.Offset, perr.Column)
+		}
+		if perr.ExprPos != c.exprPos || perr.ExprEnd != c.exprEnd {
+			t.Errorf("on %q: expected expression [%d, %d), got [%d, %d)", c.raw, c.exprPos, c.exprEnd, perr.ExprPos, perr.ExprEnd)
+		}
+	}
+}
+
+func TestExpandError(t *testing.T) {
+	tmpl := MustNew("/search{?q,lang}")
+	_, err := tmpl.Expand(Values{"lang": String("j\xffa")})
+
+	var eerr *ExpandError
+	if !errors.As(err, &eerr) {
+		t.Fatalf("expected *ExpandError, got %#v", err)
+	}
+	if !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("expected %v, got %v", ErrInvalidEncoding, eerr.Err)
+	}
+	if eerr.Varname != "lang" || eerr.Offset != 1 || eerr.ExprPos != 7 || eerr.ExprEnd != 16 {
+		t.Errorf("unexpected error %#v", eerr)
+	}
+}
@@ -7,6 +7,7 @@
 package uritemplate
 
 import (
+	"io"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -49,12 +50,18 @@ var (
 	reUnreserved = `\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e`
 )
 
-type runeClass uint8
+type runeClass uint16
 
 const (
 	runeClassU runeClass = 1 << iota
 	runeClassR
 	runeClassPctE
+	// the first hex digits of pct-encoded triplets of UTF-8 sequences
+	runeClassPctASCII
+	runeClassPctCont
+	runeClassPctLead2
+	runeClassPctLead3
+	runeClassPctLead4
 	runeClassLast
 
 	runeClassUR = runeClassU | runeClassR
@@ -64,6 +71,11 @@ var runeClassNames = []string{
 	"U",
 	"R",
 	"pct-encoded",
+	"pct-encoded ASCII",
+	"pct-encoded continuation",
+	"pct-encoded lead of 2",
+	"pct-encoded lead of 3",
+	"pct-encoded lead of 4",
 }
 
 func (rc runeClass) String() string {
@@ -77,19 +89,89 @@ func (rc runeClass) String() string {
 	return strings.Join(ret, "+")
 }
 
-func pctEncode(w *strings.Builder, r rune) {
-	if s := r >> 24 & 0xff; s > 0 {
-		w.Write([]byte{'%', hex[s/16], hex[s%16]})
+// writer is the destination of expansions. It is implemented by
+// *strings.Builder, *bytes.Buffer and *appendWriter.
+type writer interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+	WriteRune(r rune) (int, error)
+}
+
+// appendWriter is a writer that appends to a byte slice.
+type appendWriter []byte
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	*w = append(*w, p...)
+	return len(p), nil
+}
+
+func (w *appendWriter) WriteByte(c byte) error {
+	*w = append(*w, c)
+	return nil
+}
+
+func (w *appendWriter) WriteString(s string) (int, error) {
+	*w = append(*w, s...)
+	return len(s), nil
+}
+
+func (w *appendWriter) WriteRune(r rune) (int, error) {
+	if r < utf8.RuneSelf {
+		*w = append(*w, byte(r))
+		return 1, nil
 	}
-	if s := r >> 16 & 0xff; s > 0 {
-		w.Write([]byte{'%', hex[s/16], hex[s%16]})
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	*w = append(*w, buf[:n]...)
+	return n, nil
+}
+
+func writeTriplet(w writer, c byte) {
+	w.WriteByte('%')
+	w.WriteByte(hex[c>>4])
+	w.WriteByte(hex[c&0xf])
+}
+
+// pctEncode writes the UTF-8 encoding of r as pct-encoded triplets.
+func pctEncode(w writer, r rune) {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	for i := 0; i < n; i++ {
+		writeTriplet(w, buf[i])
 	}
-	if s := r >> 8 & 0xff; s > 0 {
-		w.Write([]byte{'%', hex[s/16], hex[s%16]})
+}
+
+// contains reports whether rc contains r.
+func (rc runeClass) contains(r rune) bool {
+	switch {
+	case rc&runeClassU == runeClassU && unicode.Is(rangeUnreserved, r):
+		return true
+	case rc&runeClassR == runeClassR && unicode.Is(rangeReserved, r):
+		return true
+	case rc&runeClassPctE == runeClassPctE && unicode.Is(unicode.ASCII_Hex_Digit, r):
+		return true
 	}
-	if s := r & 0xff; s > 0 {
-		w.Write([]byte{'%', hex[s/16], hex[s%16]})
+	return rc&pctLeadClass(r) != 0
+}
+
+// pctLeadClass returns the class of the first hex digit c of a
+// pct-encoded triplet, which tells the position of the octet in a UTF-8
+// sequence.
+func pctLeadClass(c rune) runeClass {
+	switch {
+	case '0' <= c && c <= '7':
+		return runeClassPctASCII
+	case '8' <= c && c <= '9', 'A' <= c && c <= 'B', 'a' <= c && c <= 'b':
+		return runeClassPctCont
+	case c == 'C' || c == 'D' || c == 'c' || c == 'd':
+		return runeClassPctLead2
+	case c == 'E' || c == 'e':
+		return runeClassPctLead3
+	case c == 'F' || c == 'f':
+		return runeClassPctLead4
 	}
+	return 0
 }
 
 func unhex(c byte) byte {
@@ -149,18 +231,44 @@ func pctDecode(s string) string {
 	return string(buf)
 }
 
-type escapeFunc func(*strings.Builder, string) error
+func normalizeLiterals(w *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		if s[i] != '%' {
+			w.WriteByte(s[i])
+			i++
+			continue
+		}
+		// It is confirmed at parse time that '%' in literals always
+		// starts a pct-encoded triplet.
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if c < utf8.RuneSelf && unicode.Is(rangeUnreserved, rune(c)) {
+			w.WriteByte(c)
+		} else {
+			writeTriplet(w, c)
+		}
+		i += 3
+	}
+}
 
-func escapeLiteral(w *strings.Builder, v string) error {
-	w.WriteString(v)
-	return nil
+func upperTriplets(w *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		if s[i] != '%' {
+			w.WriteByte(s[i])
+			i++
+			continue
+		}
+		writeTriplet(w, unhex(s[i+1])<<4|unhex(s[i+2]))
+		i += 3
+	}
 }
 
-func escapeExceptU(w *strings.Builder, v string) error {
+type escapeFunc func(writer, string) error
+
+func escapeExceptU(w writer, v string) error {
 	for i := 0; i < len(v); {
 		r, size := utf8.DecodeRuneInString(v[i:])
 		if r == utf8.RuneError {
-			return errorf(i, "invalid encoding")
+			return &ExpandError{Offset: i, Err: ErrInvalidEncoding}
 		}
 		if unicode.Is(rangeUnreserved, r) {
 			w.WriteRune(r)
@@ -172,13 +280,20 @@ func escapeExceptU(w *strings.Builder, v string) error {
 	return nil
 }
 
-func escapeExceptUR(w *strings.Builder, v string) error {
+func escapeExceptUR(w writer, v string) error {
 	for i := 0; i < len(v); {
 		r, size := utf8.DecodeRuneInString(v[i:])
 		if r == utf8.RuneError {
-			return errorf(i, "invalid encoding")
+			return &ExpandError{Offset: i, Err: ErrInvalidEncoding}
+		}
+		// pct-encoded triplets are passed through as they are.
+		//
+		// -- https://tools.ietf.org/html/rfc6570#section-3.2.3
+		if r == '%' && i+2 < len(v) && ishex(v[i+1]) && ishex(v[i+2]) {
+			w.WriteString(v[i : i+3])
+			i += 3
+			continue
 		}
-		// TODO(yosida95): is pct-encoded triplets allowed here?
 		if unicode.In(r, rangeUnreserved, rangeReserved) {
 			w.WriteRune(r)
 		} else {
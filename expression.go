@@ -7,38 +7,33 @@
 package uritemplate
 
 import (
-	"regexp"
 	"strconv"
 	"strings"
 )
 
 type template interface {
-	expand(*strings.Builder, Values) error
-	regexp(*strings.Builder)
+	expand(writer, Values) error
+	regexp(*regexpBuilder)
 }
 
 type literals string
 
-func (l literals) expand(b *strings.Builder, _ Values) error {
+func (l literals) expand(b writer, _ Values) error {
 	b.WriteString(string(l))
 	return nil
 }
 
-func (l literals) regexp(b *strings.Builder) {
+func (l literals) regexp(b *regexpBuilder) {
 	b.WriteString("(?:")
-	b.WriteString(regexp.QuoteMeta(string(l)))
+	b.WriteString(b.quote(string(l)))
 	b.WriteByte(')')
 }
 
-type varspec struct {
-	name    string
-	maxlen  int
-	explode bool
-}
-
 type expression struct {
-	vars   []varspec
-	op     parseOp
+	vars   []Varspec
+	op     Operator
+	pos    int
+	end    int
 	first  string
 	sep    string
 	named  bool
@@ -49,43 +44,43 @@ type expression struct {
 
 func (e *expression) init() {
 	switch e.op {
-	case parseOpSimple:
+	case OpSimple:
 		e.sep = ","
 		e.escape = escapeExceptU
 		e.allow = runeClassU
-	case parseOpPlus:
+	case OpPlus:
 		e.sep = ","
 		e.escape = escapeExceptUR
 		e.allow = runeClassUR
-	case parseOpCrosshatch:
+	case OpCrosshatch:
 		e.first = "#"
 		e.sep = ","
 		e.escape = escapeExceptUR
 		e.allow = runeClassUR
-	case parseOpDot:
+	case OpDot:
 		e.first = "."
 		e.sep = "."
 		e.escape = escapeExceptU
 		e.allow = runeClassU
-	case parseOpSlash:
+	case OpSlash:
 		e.first = "/"
 		e.sep = "/"
 		e.escape = escapeExceptU
 		e.allow = runeClassU
-	case parseOpSemicolon:
+	case OpSemicolon:
 		e.first = ";"
 		e.sep = ";"
 		e.named = true
 		e.escape = escapeExceptU
 		e.allow = runeClassU
-	case parseOpQuestion:
+	case OpQuestion:
 		e.first = "?"
 		e.sep = "&"
 		e.named = true
 		e.ifemp = "="
 		e.escape = escapeExceptU
 		e.allow = runeClassU
-	case parseOpAmpersand:
+	case OpAmpersand:
 		e.first = "&"
 		e.sep = "&"
 		e.named = true
@@ -95,10 +90,24 @@ func (e *expression) init() {
 	}
 }
 
-func (e *expression) expand(w *strings.Builder, values Values) error {
+func (e *expression) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	b.WriteString(e.op.String())
+	for i, spec := range e.vars {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(spec.String())
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (e *expression) expand(w writer, values Values) error {
 	first := true
-	for _, varspec := range e.vars {
-		value := values.Get(varspec.name)
+	for _, spec := range e.vars {
+		value := values.Get(spec.name)
 		if !value.Valid() {
 			continue
 		}
@@ -110,7 +119,12 @@ func (e *expression) expand(w *strings.Builder, values Values) error {
 			w.WriteString(e.sep)
 		}
 
-		if err := value.expand(w, varspec, e); err != nil {
+		if err := value.expand(w, spec, e); err != nil {
+			if err, ok := err.(*ExpandError); ok {
+				err.Varname = spec.name
+				err.ExprPos = e.pos
+				err.ExprEnd = e.end
+			}
 			return err
 		}
 
@@ -118,56 +132,130 @@ func (e *expression) expand(w *strings.Builder, values Values) error {
 	return nil
 }
 
-func (e *expression) regexp(b *strings.Builder) {
-	if e.first != "" {
-		b.WriteString("(?:") // $1
-		b.WriteString(regexp.QuoteMeta(e.first))
+// regexp writes the regexp that matches each varspec in the same way as
+// compiler does. The part following the first character is captured in a
+// numbered group, or each varspec in a named group if b.opts.NamedGroups.
+func (e *expression) regexp(b *regexpBuilder) {
+	b.WriteString("(?:")
+	b.WriteString(b.quote(e.first))
+	if !b.opts.NamedGroups {
+		b.WriteByte('(')
 	}
-	b.WriteByte('(') // $2
-	runeClassToRegexp(b, e.allow, e.named || e.vars[0].explode)
-	if len(e.vars) > 1 || e.vars[0].explode {
-		max := len(e.vars) - 1
-		for i := 0; i < len(e.vars); i++ {
-			if e.vars[i].explode {
-				max = -1
-				break
-			}
-		}
-
-		b.WriteString("(?:") // $3
-		b.WriteString(regexp.QuoteMeta(e.sep))
-		runeClassToRegexp(b, e.allow, e.named || max < 0)
-		b.WriteByte(')') // $3
-		if max > 0 {
-			b.WriteString("{0,")
-			b.WriteString(strconv.Itoa(max))
-			b.WriteByte('}')
-		} else {
-			b.WriteByte('*')
+	for i, spec := range e.vars {
+		b.WriteString("(?:")
+		if i > 0 {
+			b.WriteString("(?:")
+			b.WriteString(b.quote(e.sep))
+			b.WriteString(")?")
 		}
+		e.regexpVarspec(b, spec)
+		b.WriteString(")?")
 	}
-	b.WriteByte(')') // $2
-	if e.first != "" {
-		b.WriteByte(')') // $1
+	if !b.opts.NamedGroups {
+		b.WriteByte(')')
 	}
-	b.WriteByte('?')
+	b.WriteString(")?")
 }
 
-func runeClassToRegexp(b *strings.Builder, class runeClass, named bool) {
-	b.WriteString("(?:(?:[")
-	if class&runeClassR == 0 {
-		b.WriteString(`\x2c`)
-		if named {
-			b.WriteString(`\x3d`)
-		}
+func (e *expression) regexpVarspec(b *regexpBuilder, spec Varspec) {
+	switch {
+	case e.named && spec.explode:
+		// name=value, or key=value of associative arrays
+		b.startGroup(spec)
+		e.regexpNamedElem(b)
+		b.WriteString("(?:")
+		b.WriteString(b.quote(e.sep))
+		e.regexpNamedElem(b)
+		b.WriteString(")*")
+		b.endGroup()
+	case e.named:
+		b.WriteString(b.quote(spec.name))
+		b.WriteString("(?:=")
+		b.startGroup(spec)
+		runeClassToRegexp(b, e.allow, spec.maxlen)
+		b.WriteString("(?:,")
+		runeClassToRegexp(b, e.allow, spec.maxlen)
+		b.WriteString(")*")
+		b.endGroup()
+		b.WriteByte('|')
+		b.WriteString(b.quote(e.ifemp))
+		b.WriteByte(')')
+	case spec.explode:
+		// values, or key=value of associative arrays
+		b.startGroup(spec)
+		e.regexpList(b, e.sep, 0, false)
+		b.WriteByte('|')
+		e.regexpList(b, e.sep, 0, true)
+		b.endGroup()
+	default:
+		b.startGroup(spec)
+		e.regexpList(b, ",", spec.maxlen, false)
+		b.endGroup()
 	}
+}
+
+func (e *expression) regexpList(b *regexpBuilder, sep string, maxlen int, kv bool) {
+	e.regexpElem(b, maxlen, kv)
+	b.WriteString("(?:")
+	b.WriteString(b.quote(sep))
+	e.regexpElem(b, maxlen, kv)
+	b.WriteString(")*?")
+}
+
+func (e *expression) regexpNamedElem(b *regexpBuilder) {
+	runeClassToRegexp(b, e.allow, 0)
+	b.WriteString("(?:=")
+	runeClassToRegexp(b, e.allow, 0)
+	b.WriteByte('|')
+	b.WriteString(b.quote(e.ifemp))
+	b.WriteByte(')')
+}
+
+func (e *expression) regexpElem(b *regexpBuilder, maxlen int, kv bool) {
+	runeClassToRegexp(b, e.allow, maxlen)
+	if kv {
+		b.WriteByte('=')
+		runeClassToRegexp(b, e.allow, 0)
+	}
+}
+
+// maxRegexpRepeat is the maximum count of a repetition RE2 accepts.
+const maxRegexpRepeat = 1000
+
+// runeClassToRegexp writes the regexp that matches a value consisting of
+// the characters in class and pct-encoded triplets. The triplets of a
+// UTF-8 sequence are counted as one character against maxlen unless maxlen
+// is less than 1.
+func runeClassToRegexp(b *regexpBuilder, class runeClass, maxlen int) {
+	var char strings.Builder
+	char.WriteString("(?:[")
 	if class&runeClassU == runeClassU {
-		b.WriteString(reUnreserved)
+		char.WriteString(reUnreserved)
 	}
 	if class&runeClassR == runeClassR {
-		b.WriteString(reReserved)
+		char.WriteString(reReserved)
+	}
+	char.WriteByte(']')
+	if maxlen < 1 {
+		char.WriteString("|%" + b.xdigit() + b.xdigit() + ")*")
+		b.WriteString(char.String())
+		return
+	}
+
+	cont := "%[89ABab]" + b.xdigit()
+	char.WriteString("|%[0-7]" + b.xdigit())
+	char.WriteString("|%[CDcd]" + b.xdigit() + cont)
+	char.WriteString("|%[Ee]" + b.xdigit() + cont + cont)
+	char.WriteString("|%[Ff]" + b.xdigit() + cont + cont + cont)
+	char.WriteByte(')')
+	for ; maxlen > 0; maxlen -= maxRegexpRepeat {
+		n := maxlen
+		if n > maxRegexpRepeat {
+			n = maxRegexpRepeat
+		}
+		b.WriteString(char.String())
+		b.WriteString("{0,")
+		b.WriteString(strconv.Itoa(n))
+		b.WriteByte('}')
 	}
-	b.WriteString("]")
-	b.WriteString("|%[[:xdigit:]][[:xdigit:]]")
-	b.WriteString(")*)")
 }
@@ -0,0 +1,190 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries vars.
+func NewContext(ctx context.Context, vars Values) context.Context {
+	return context.WithValue(ctx, contextKey{}, vars)
+}
+
+// FromContext returns the variables carried by ctx, or nil if ctx carries
+// none. ServeMux stores the variables matched with the request URI in the
+// request context.
+func FromContext(ctx context.Context) Values {
+	vars, _ := ctx.Value(contextKey{}).(Values)
+	return vars
+}
+
+// ServeMux is an HTTP request multiplexer that dispatches each request to
+// the handler registered for the template matching the request URI and
+// the request method. Templates are matched against the escaped path of
+// the request URI followed by the raw query, if any, in the way Router
+// does. The matched variables are available to the handler via
+// FromContext.
+//
+// When more than one template matches, the request goes to the one with
+// the highest precedence that has a handler for the request method.
+// ServeMux replies 404 Not Found if no template matches, and 405 Method
+// Not Allowed if none of the matching templates has a handler for the
+// request method.
+//
+// The zero value for ServeMux is an empty ServeMux ready to use.
+type ServeMux struct {
+	// VerifyURLs makes URL check that the URL it builds matches the
+	// template again. It is intended for debugging.
+	VerifyURLs bool
+
+	mu       sync.RWMutex
+	router   Router
+	ids      map[string]int
+	handlers []map[string]http.Handler // method to handler by route ID
+}
+
+// NewServeMux allocates and returns a new ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{}
+}
+
+// Handle registers handler for requests with method whose URI matches
+// tmpl. If method is empty, handler serves requests with any method that
+// has no handler of its own. Handle panics if a handler already exists for
+// method and tmpl.
+func (mux *ServeMux) Handle(method string, tmpl *Template, handler http.Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.handle(method, tmpl, handler)
+}
+
+func (mux *ServeMux) handle(method string, tmpl *Template, handler http.Handler) int {
+	if handler == nil {
+		panic("uritemplate: nil handler")
+	}
+	id, ok := mux.ids[tmpl.Raw()]
+	if ok {
+		if _, ok := mux.handlers[id][method]; ok {
+			panic("uritemplate: multiple registrations for " + method + " " + tmpl.Raw())
+		}
+	} else {
+		if mux.ids == nil {
+			mux.ids = make(map[string]int)
+		}
+		id = mux.router.Add(tmpl)
+		mux.ids[tmpl.Raw()] = id
+		mux.handlers = append(mux.handlers, make(map[string]http.Handler))
+	}
+	mux.handlers[id][method] = handler
+	return id
+}
+
+// HandleNamed is like Handle, but also registers tmpl under name for URL.
+// HandleNamed panics if name is already registered for another template,
+// and registers nothing in that case.
+func (mux *ServeMux) HandleNamed(name string, method string, tmpl *Template, handler http.Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	named, ok := mux.router.Named(name)
+	if ok {
+		if id, ok := mux.ids[tmpl.Raw()]; !ok || id != named {
+			panic("uritemplate: multiple registrations for " + name)
+		}
+	}
+	id := mux.handle(method, tmpl, handler)
+	if !ok {
+		mux.router.setName(id, name)
+	}
+}
+
+// URL builds the URL for the template registered under name.
+// See Router.URL.
+func (mux *ServeMux) URL(name string, vars Values) (string, error) {
+	return mux.router.url(name, vars, mux.VerifyURLs)
+}
+
+// HandleFunc registers the handler function for requests with method whose
+// URI matches tmpl. See Handle.
+func (mux *ServeMux) HandleFunc(method string, tmpl *Template, handler func(http.ResponseWriter, *http.Request)) {
+	if handler == nil {
+		panic("uritemplate: nil handler")
+	}
+	mux.Handle(method, tmpl, http.HandlerFunc(handler))
+}
+
+// Handler returns the handler to use for r, the variables matched with
+// the request URI, and the template matched. If no template matches r,
+// it returns nil template and a handler that replies 404 Not Found. If
+// none of the matching templates has a handler for the method of r, it
+// returns the one with the highest precedence and a handler that replies
+// 405 Method Not Allowed.
+func (mux *ServeMux) Handler(r *http.Request) (http.Handler, Values, *Template) {
+	uri := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" || r.URL.ForceQuery {
+		uri += "?" + r.URL.RawQuery
+	}
+
+	matches := mux.router.lookupAll(uri)
+	if len(matches) == 0 {
+		return http.NotFoundHandler(), nil, nil
+	}
+
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	var allow []string
+	for _, match := range matches {
+		handlers := mux.handlers[match.id]
+		if h := handlerForMethod(handlers, r.Method); h != nil {
+			return h, match.vars, mux.router.Template(match.id)
+		}
+		for method := range handlers {
+			allow = appendUnique(allow, method)
+		}
+		if _, ok := handlers[http.MethodGet]; ok {
+			allow = appendUnique(allow, http.MethodHead)
+		}
+	}
+	sort.Strings(allow)
+	return methodNotAllowedHandler(strings.Join(allow, ", ")), matches[0].vars, mux.router.Template(matches[0].id)
+}
+
+// handlerForMethod returns the handler in handlers for method, or nil if
+// there is none.
+func handlerForMethod(handlers map[string]http.Handler, method string) http.Handler {
+	if h, ok := handlers[method]; ok {
+		return h
+	}
+	if h, ok := handlers[http.MethodGet]; ok && method == http.MethodHead {
+		return h
+	}
+	return handlers[""]
+}
+
+// ServeHTTP dispatches the request to the handler whose template matches
+// the request URI.
+func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, vars, tmpl := mux.Handler(r)
+	if tmpl != nil {
+		r = r.WithContext(NewContext(r.Context(), vars))
+	}
+	h.ServeHTTP(w, r)
+}
+
+func methodNotAllowedHandler(allow string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		code := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(code), code)
+	})
+}
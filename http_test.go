@@ -0,0 +1,117 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func ExampleServeMux() {
+	mux := NewServeMux()
+	mux.HandleFunc(http.MethodGet, MustNew("/users/{id}{?fields}"), func(w http.ResponseWriter, r *http.Request) {
+		vars := FromContext(r.Context())
+		fmt.Fprintf(w, "user %s (%s)", vars.Get("id").String(), vars.Get("fields").String())
+	})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/yosida95?fields=name", nil))
+	fmt.Println(rec.Code, rec.Body.String())
+
+	// Output:
+	// 200 user yosida95 (name)
+}
+
+func TestServeMux(t *testing.T) {
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			vars := make(map[string][]string)
+			for name, v := range FromContext(r.Context()) {
+				vars[name] = v.V
+			}
+			fmt.Fprintf(w, "%s %v", name, vars)
+		}
+	}
+
+	var mux ServeMux
+	mux.Handle(http.MethodGet, MustNew("/users/{id}"), handler("show"))
+	mux.Handle(http.MethodPut, MustNew("/users/{id}"), handler("update"))
+	mux.Handle(http.MethodGet, MustNew("/users/me"), handler("me"))
+	mux.Handle("", MustNew("/files{/path*}"), handler("files"))
+	mux.Handle(http.MethodDelete, MustNew("/posts/latest"), handler("delete latest"))
+	mux.Handle(http.MethodGet, MustNew("/posts/{id}"), handler("post"))
+
+	for _, c := range []struct {
+		method string
+		target string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/users/1", http.StatusOK, "show map[id:[1]]", ""},
+		{http.MethodHead, "/users/1", http.StatusOK, "show map[id:[1]]", ""},
+		{http.MethodPut, "/users/a%2Fb", http.StatusOK, "update map[id:[a/b]]", ""},
+		{http.MethodGet, "/users/me", http.StatusOK, "me map[]", ""},
+		{http.MethodDelete, "/users/1", http.StatusMethodNotAllowed, "Method Not Allowed\n", "GET, HEAD, PUT"},
+		{http.MethodPost, "/files/a/b", http.StatusOK, "files map[path:[a b]]", ""},
+		{http.MethodGet, "/users/1?x=y", http.StatusNotFound, "404 page not found\n", ""},
+		{http.MethodGet, "/posts", http.StatusNotFound, "404 page not found\n", ""},
+		{http.MethodGet, "/posts/latest", http.StatusOK, "post map[id:[latest]]", ""},
+		{http.MethodHead, "/posts/latest", http.StatusOK, "post map[id:[latest]]", ""},
+		{http.MethodDelete, "/posts/latest", http.StatusOK, "delete latest map[]", ""},
+		{http.MethodPatch, "/posts/latest", http.StatusMethodNotAllowed, "Method Not Allowed\n", "DELETE, GET, HEAD"},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(c.method, c.target, nil))
+		if rec.Code != c.code || rec.Body.String() != c.body || rec.Header().Get("Allow") != c.allow {
+			t.Errorf("%s %s: unexpected response %d %q (Allow: %q)", c.method, c.target, rec.Code, rec.Body.String(), rec.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestServeMux_URL(t *testing.T) {
+	mux := ServeMux{VerifyURLs: true}
+	tmpl := MustNew("/users/{id}")
+	mux.HandleNamed("user", http.MethodGet, tmpl, http.NotFoundHandler())
+	mux.HandleNamed("user", http.MethodPut, tmpl, http.NotFoundHandler())
+
+	if uri, err := mux.URL("user", Values{"id": String("a/b")}); err != nil || uri != "/users/a%2Fb" {
+		t.Errorf("unexpected result: %q, %v", uri, err)
+	}
+}
+
+func TestServeMux_DuplicatedRegistration(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("must panic")
+		}
+	}()
+
+	var mux ServeMux
+	mux.Handle(http.MethodGet, MustNew("/users/{id}"), http.NotFoundHandler())
+	mux.Handle(http.MethodGet, MustNew("/users/{id}"), http.NotFoundHandler())
+}
+
+func TestServeMux_HandleNamed_Duplicated(t *testing.T) {
+	var mux ServeMux
+	defer func() {
+		if recover() == nil {
+			t.Errorf("must panic")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/b", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("/b must not be registered, but got %d", rec.Code)
+		}
+	}()
+
+	mux.HandleNamed("a", http.MethodGet, MustNew("/a"), http.NotFoundHandler())
+	mux.HandleNamed("a", http.MethodPost, MustNew("/a"), http.NotFoundHandler())
+	mux.HandleNamed("a", http.MethodGet, MustNew("/b"), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+}
@@ -0,0 +1,277 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity int
+
+const (
+	// SeverityInfo reports a usage that may be intended.
+	SeverityInfo Severity = iota
+	// SeverityWarning reports a usage that is likely to be a mistake.
+	SeverityWarning
+	// SeverityError reports a usage that makes expansions fail or
+	// produce invalid URIs.
+	SeverityError
+	severityLast
+)
+
+var severityNames = []string{
+	"info",
+	"warning",
+	"error",
+}
+
+func (s Severity) String() string {
+	if 0 <= s && s < severityLast {
+		return severityNames[s]
+	}
+	return ""
+}
+
+// Diagnostic is a problem found in a template by Lint.
+type Diagnostic struct {
+	// Pos and End are the byte offsets of the problem in the raw
+	// template.
+	Pos int
+	End int
+
+	Column int // 1-based column of Pos in runes
+
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d: %s: %s", d.Column, d.Severity, d.Message)
+}
+
+// uriComponent is the component of a URI that a part of a template is in.
+type uriComponent int
+
+const (
+	componentAuthority uriComponent = iota
+	componentPath
+	componentQuery
+	componentFragment
+)
+
+type linter struct {
+	diags []Diagnostic
+	comp  uriComponent
+
+	// uses holds the varspecs of each variable in the order of appearance.
+	uses map[string][]Varspec
+}
+
+func (l *linter) report(pos, end int, severity Severity, format string, args ...interface{}) {
+	l.diags = append(l.diags, Diagnostic{
+		Pos:      pos,
+		End:      end,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint reports legal but problematic usages in t, sorted by position.
+func Lint(t *Template) []Diagnostic {
+	l := linter{
+		comp: componentPath,
+		uses: make(map[string][]Varspec),
+	}
+
+	parts := t.Parts()
+	for i, part := range parts {
+		switch part := part.(type) {
+		case *Literal:
+			start := 0
+			if i == 0 {
+				l.comp, start = startComponent(part.text)
+			}
+			l.lintLiteral(part, start)
+		case *Expression:
+			if i > 0 {
+				if prev, ok := parts[i-1].(*Expression); ok {
+					l.lintAdjacent(prev.expr, part.expr)
+				}
+			}
+			l.lintExpression(part.expr)
+		}
+	}
+	l.lintUses()
+	l.lintURL(t)
+
+	raw := t.Raw()
+	for i := range l.diags {
+		l.diags[i].Column = utf8.RuneCountInString(raw[:l.diags[i].Pos]) + 1
+	}
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		return l.diags[i].Pos < l.diags[j].Pos
+	})
+	return l.diags
+}
+
+// startComponent returns the component at the beginning of a template
+// whose first literal is s, and the offset in s where it starts.
+func startComponent(s string) (uriComponent, int) {
+	if len(s) >= 2 && s[:2] == "//" {
+		return componentAuthority, 2
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		case i > 0 && c == ':':
+			if len(s) >= i+3 && s[i+1:i+3] == "//" {
+				return componentAuthority, i + 3
+			}
+			return componentPath, i + 1
+		default:
+			return componentPath, 0
+		}
+	}
+	return componentPath, 0
+}
+
+func (l *linter) lintLiteral(lit *Literal, start int) {
+	s := lit.text
+	for i := start; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		pos := lit.pos + i
+		switch {
+		case r >= utf8.RuneSelf:
+			l.report(pos, pos+size, SeverityWarning, "non-ASCII character %q should be pct-encoded", r)
+		case r == '/' && l.comp == componentAuthority:
+			l.comp = componentPath
+		case r == '?' && l.comp < componentQuery:
+			l.comp = componentQuery
+		case r == '#' && l.comp < componentFragment:
+			l.comp = componentFragment
+		case r == '#':
+			l.report(pos, pos+size, SeverityWarning, "%q in the fragment should be pct-encoded", r)
+		case (r == '[' || r == ']') && l.comp != componentAuthority:
+			l.report(pos, pos+size, SeverityWarning, "%q outside the host should be pct-encoded", r)
+		}
+		i += size
+	}
+}
+
+func (l *linter) lintExpression(expr *expression) {
+	for _, spec := range expr.vars {
+		l.uses[spec.name] = append(l.uses[spec.name], spec)
+	}
+
+	switch expr.op {
+	case OpQuestion:
+		switch l.comp {
+		case componentQuery:
+			l.report(expr.pos, expr.end, SeverityWarning, "%s in the query starts another query; use {&...}", expr)
+		case componentFragment:
+			l.report(expr.pos, expr.end, SeverityWarning, "%s is in the fragment", expr)
+		default:
+			l.comp = componentQuery
+		}
+	case OpAmpersand:
+		switch l.comp {
+		case componentQuery:
+		case componentFragment:
+			l.report(expr.pos, expr.end, SeverityWarning, "%s is in the fragment", expr)
+		default:
+			l.report(expr.pos, expr.end, SeverityWarning, "%s is outside the query; use {?...}", expr)
+		}
+	case OpCrosshatch:
+		if l.comp < componentFragment {
+			l.comp = componentFragment
+		}
+	case OpSlash:
+		if l.comp == componentAuthority {
+			l.comp = componentPath
+		}
+	}
+}
+
+// lintAdjacent reports next if it immediately follows prev and Match
+// cannot tell where the expansion of prev ends.
+func (l *linter) lintAdjacent(prev, next *expression) {
+	ambiguous := next.first == ""
+	if !ambiguous {
+		r, _ := utf8.DecodeRuneInString(next.first)
+		ambiguous = prev.allow&runeClassU == runeClassU && unicode.Is(rangeUnreserved, r) ||
+			prev.allow&runeClassR == runeClassR && unicode.Is(rangeReserved, r)
+	}
+	if ambiguous {
+		l.report(next.pos, next.end, SeverityWarning, "%s immediately follows %s; Match cannot tell where one ends", next, prev)
+	}
+}
+
+func (l *linter) lintUses() {
+	names := make([]string, 0, len(l.uses))
+	for name := range l.uses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var exploded, full, prefixed *Varspec
+		for i := range l.uses[name] {
+			spec := &l.uses[name][i]
+			switch {
+			case spec.explode:
+				if exploded == nil {
+					exploded = spec
+				}
+			case spec.maxlen > 0:
+				if prefixed == nil {
+					prefixed = spec
+				}
+			default:
+				if full == nil {
+					full = spec
+				}
+			}
+		}
+
+		if exploded != nil && full != nil {
+			later := laterVarspec(exploded, full)
+			l.report(later.pos, later.end, SeverityWarning, "%s is used both with and without the explode modifier", name)
+		}
+		if exploded != nil && prefixed != nil {
+			l.report(prefixed.pos, prefixed.end, SeverityError, "prefix modifier on %s, which is exploded as a composite value elsewhere", name)
+		} else if full != nil && prefixed != nil {
+			later := laterVarspec(prefixed, full)
+			l.report(later.pos, later.end, SeverityInfo, "%s is used both as a prefix and in full", name)
+		}
+	}
+}
+
+func laterVarspec(a, b *Varspec) *Varspec {
+	if a.pos > b.pos {
+		return a
+	}
+	return b
+}
+
+// lintURL reports the error url.Parse returns for the expansion of t with
+// no variables defined.
+func (l *linter) lintURL(t *Template) {
+	s, err := t.Expand(nil)
+	if err != nil {
+		return
+	}
+	if _, err := url.Parse(s); err != nil {
+		l.report(0, len(t.raw), SeverityError, "%v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleLint() {
+	tmpl := MustNew("/search?lang=ja{?q}{&page*}&p={page}")
+	for _, d := range Lint(tmpl) {
+		fmt.Println(d)
+	}
+
+	// Output:
+	// 16: warning: {?q} in the query starts another query; use {&...}
+	// 32: warning: page is used both with and without the explode modifier
+}
+
+func TestLint(t *testing.T) {
+	for _, c := range []struct {
+		raw      string
+		expected []Diagnostic
+	}{
+		{"https://example.com/dictionary/{term:1}/{term}{?q,lang}{&page}", []Diagnostic{
+			{Pos: 41, End: 45, Severity: SeverityInfo},
+		}},
+		{"{x}/{x*}", []Diagnostic{
+			{Pos: 5, End: 7, Severity: SeverityWarning},
+		}},
+		{"{x*}/{x:3}", []Diagnostic{
+			{Pos: 6, End: 9, Severity: SeverityError},
+		}},
+		{"{a}{b}{/c}", []Diagnostic{
+			{Pos: 3, End: 6, Severity: SeverityWarning},
+		}},
+		{"{a}{.b}{+c}{#d}", []Diagnostic{
+			{Pos: 3, End: 7, Severity: SeverityWarning},
+			{Pos: 7, End: 11, Severity: SeverityWarning},
+			{Pos: 11, End: 15, Severity: SeverityWarning},
+		}},
+		{"/a{&x}", []Diagnostic{
+			{Pos: 2, End: 6, Severity: SeverityWarning},
+		}},
+		{"/a?b=c{?x}", []Diagnostic{
+			{Pos: 6, End: 10, Severity: SeverityWarning},
+		}},
+		{"/a{#f}{&x}", []Diagnostic{
+			{Pos: 6, End: 10, Severity: SeverityWarning},
+			{Pos: 6, End: 10, Severity: SeverityWarning},
+		}},
+		{"http://[::1]/ü/[a]#b#c", []Diagnostic{
+			{Pos: 13, End: 15, Column: 14, Severity: SeverityWarning},
+			{Pos: 16, End: 17, Column: 16, Severity: SeverityWarning},
+			{Pos: 18, End: 19, Column: 18, Severity: SeverityWarning},
+			{Pos: 21, End: 22, Column: 21, Severity: SeverityWarning},
+		}},
+		{"http://[{host}/", []Diagnostic{
+			{Pos: 0, End: 15, Severity: SeverityError},
+		}},
+		{"/a{/b}{?c}{&d}#e", nil},
+	} {
+		actual := Lint(MustNew(c.raw))
+		if len(actual) != len(c.expected) {
+			t.Errorf("on %q: expected %d diagnostics, but got %v", c.raw, len(c.expected), actual)
+			continue
+		}
+		for i, d := range actual {
+			e := c.expected[i]
+			if d.Pos != e.Pos || d.End != e.End || d.Severity != e.Severity {
+				t.Errorf("on %q: expected %d-%d %s, but got %d-%d %s", c.raw, e.Pos, e.End, e.Severity, d.Pos, d.End, d.Severity)
+			}
+			if e.Column != 0 && d.Column != e.Column {
+				t.Errorf("on %q: expected column %d at %d, but got %d", c.raw, e.Column, d.Pos, d.Column)
+			}
+		}
+	}
+}
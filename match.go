@@ -8,6 +8,7 @@ package uritemplate
 
 import (
 	"bytes"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -20,9 +21,31 @@ type matcher struct {
 	matched bool
 	cap     map[string][]int
 
+	// all makes the matcher run all threads to the end of input and record
+	// captures for each opEnd in ends, keyed by the operand of opEnd.
+	all  bool
+	ends map[uint32]map[string][]int
+
 	input string
 }
 
+func newMatcher(prog *prog, input string) *matcher {
+	n := len(prog.op)
+	return &matcher{
+		prog: prog,
+		list1: threadList{
+			dense:  make([]threadEntry, 0, n),
+			sparse: make([]uint32, n),
+		},
+		list2: threadList{
+			dense:  make([]threadEntry, 0, n),
+			sparse: make([]uint32, n),
+		},
+		cap:   make(map[string][]int, prog.numCap),
+		input: input,
+	}
+}
+
 func (m *matcher) at(pos int) (rune, int, bool) {
 	if l := len(m.input); pos < l {
 		c := m.input[pos]
@@ -66,7 +89,7 @@ func (m *matcher) add(list *threadList, pc uint32, pos int, next bool, cap map[s
 			m.add(list, pc+1, pos, next, cap)
 		}
 	case opLineEnd:
-		if !next {
+		if pos == len(m.input) {
 			m.add(list, pc+1, pos, next, cap)
 		}
 	case opCapStart, opCapEnd:
@@ -119,20 +142,17 @@ func (m *matcher) step(clist *threadList, nlist *threadList, r rune, pos int, ne
 				m.add(nlist, e.pc+1, nextPos, next, t.cap)
 			}
 		case opRuneClass:
-			ret := false
-			if !ret && op.rc&runeClassU == runeClassU {
-				ret = ret || unicode.Is(rangeUnreserved, r)
-			}
-			if !ret && op.rc&runeClassR == runeClassR {
-				ret = ret || unicode.Is(rangeReserved, r)
-			}
-			if !ret && op.rc&runeClassPctE == runeClassPctE {
-				ret = ret || unicode.Is(unicode.ASCII_Hex_Digit, r)
-			}
-			if ret {
+			if op.rc.contains(r) {
 				m.add(nlist, e.pc+1, nextPos, next, t.cap)
 			}
 		case opEnd:
+			if m.all {
+				if _, ok := m.ends[op.i]; !ok {
+					m.ends[op.i] = t.cap
+				}
+				m.matched = true
+				continue
+			}
 			m.matched = true
 			for k, v := range t.cap {
 				m.cap[k] = make([]int, len(v))
@@ -167,47 +187,311 @@ func (m *matcher) match() bool {
 	return m.matched
 }
 
+// Match returns variables captured from expansion if the template matches
+// expansion, or nil otherwise. Each captured variable is String, or List
+// if it is captured more than once. A variable with the explode modifier
+// is captured as KV if its elements are only valid as the pairs of an
+// associative array. Variables with a prefix modifier are captured under
+// the names with the max-length, such as "term:1".
 func (tmpl *Template) Match(expansion string) Values {
-	tmpl.mu.Lock()
-	if tmpl.prog == nil {
-		c := compiler{}
-		c.init()
-		c.compile(tmpl)
-		tmpl.prog = c.prog
+	return tmpl.MatchWithHints(expansion, nil)
+}
+
+// MatchWithHints is like Match, but captures the variables in hints as
+// the value types specified in hints. A variable hinted as ValueTypeKV is
+// matched as an associative array, and one hinted as ValueTypeList is
+// captured as List even if it has only one element. Hints do not affect
+// variables with a prefix modifier.
+func (tmpl *Template) MatchWithHints(expansion string, hints map[string]ValueType) Values {
+	return tmpl.MatchWithOptions(expansion, MatchOptions{Hints: hints})
+}
+
+// MatchOptions configures MatchWithOptions.
+type MatchOptions struct {
+	// Hints specifies the value types of variables. See MatchWithHints.
+	Hints map[string]ValueType
+
+	// UnorderedQuery makes the {?...} and {&...} expressions at the end
+	// of the template match query parameters given in any order.
+	// A parameter for a variable without the explode modifier must not
+	// appear more than once.
+	UnorderedQuery bool
+
+	// ExtraParams is the name of the variable that collects the query
+	// parameters not in the template as KV when UnorderedQuery is set.
+	// It takes precedence over a variable with the explode modifier hinted
+	// as ValueTypeKV, which collects them only if ExtraParams is empty.
+	// If neither is given, such parameters make the match fail.
+	ExtraParams string
+}
+
+// MatchWithOptions is like Match, but behaves as configured by opts.
+func (tmpl *Template) MatchWithOptions(expansion string, opts MatchOptions) Values {
+	m := newMatcher(tmpl.compile(opts.Hints, opts.UnorderedQuery), expansion)
+	if !m.match() {
+		return nil
 	}
-	prog := tmpl.prog
-	tmpl.mu.Unlock()
 
-	n := len(prog.op)
-	m := matcher{
-		prog: prog,
-		list1: threadList{
-			dense:  make([]threadEntry, 0, n),
-			sparse: make([]uint32, n),
-		},
-		list2: threadList{
-			dense:  make([]threadEntry, 0, n),
-			sparse: make([]uint32, n),
-		},
-		cap:   make(map[string][]int, prog.numCap),
-		input: expansion,
+	indices, ok := m.cap[queryCapName]
+	if !ok {
+		return captureValues(expansion, m.cap, opts.Hints)
 	}
-	if !m.match() {
+	delete(m.cap, queryCapName)
+
+	_, query := splitQueryExprs(tmpl.exprs)
+	elems, ok := matchQuery(expansion[indices[0]:indices[1]], query, opts)
+	if !ok {
 		return nil
 	}
+	match := captureValues(expansion, m.cap, opts.Hints)
+	for name, v := range elems {
+		if name == opts.ExtraParams {
+			match[name] = KV(v...)
+			continue
+		}
+		match[name] = capturedValue(v, opts.Hints[name])
+	}
+	return match
+}
+
+// strictProgKey is the key of the program compiled in the strict mode in
+// Template.progs, which never collides with the names of variables.
+const strictProgKey = "!"
+
+// strictProg returns the program of the template that matches only what
+// Expand produces.
+func (tmpl *Template) strictProg() *prog {
+	tmpl.mu.Lock()
+	defer tmpl.mu.Unlock()
+	if prog, ok := tmpl.progs[strictProgKey]; ok {
+		return prog
+	}
+	c := compiler{strict: true}
+	c.init()
+	c.compile(tmpl)
+	if tmpl.progs == nil {
+		tmpl.progs = make(map[string]*prog)
+	}
+	tmpl.progs[strictProgKey] = c.prog
+	return c.prog
+}
+
+// compile returns the program of the template compiled with hints.
+// Programs are cached per set of variables hinted as ValueTypeKV.
+func (tmpl *Template) compile(hints map[string]ValueType, unorderedQuery bool) *prog {
+	var key strings.Builder
+	if unorderedQuery {
+		key.WriteString(queryCapName)
+	}
+	for _, name := range tmpl.Varnames() {
+		if hints[name] == ValueTypeKV {
+			key.WriteString(name)
+			key.WriteByte(',')
+		}
+	}
+
+	tmpl.mu.Lock()
+	defer tmpl.mu.Unlock()
+	if key.Len() == 0 {
+		if tmpl.prog == nil {
+			c := compiler{}
+			c.init()
+			c.compile(tmpl)
+			tmpl.prog = c.prog
+		}
+		return tmpl.prog
+	}
+
+	if prog, ok := tmpl.progs[key.String()]; ok {
+		return prog
+	}
+	c := compiler{
+		hints:          hints,
+		unorderedQuery: unorderedQuery,
+	}
+	c.init()
+	c.compile(tmpl)
+	if tmpl.progs == nil {
+		tmpl.progs = make(map[string]*prog)
+	}
+	tmpl.progs[key.String()] = c.prog
+	return c.prog
+}
 
-	match := make(Values, len(m.cap))
-	for name, indices := range m.cap {
-		v := Value{V: make([]string, len(indices)/2)}
-		for i := range v.V {
-			v.V[i] = pctDecode(expansion[indices[2*i]:indices[2*i+1]])
+func captureValues(expansion string, cap map[string][]int, hints map[string]ValueType) Values {
+	match := make(Values, len(cap))
+	for name, indices := range cap {
+		v := make([]string, len(indices)/2)
+		for i := range v {
+			v[i] = pctDecode(expansion[indices[2*i]:indices[2*i+1]])
 		}
-		if len(v.V) == 1 {
-			v.T = ValueTypeString
-		} else {
-			v.T = ValueTypeList
+		if varname, ok := kvCapVarname(name); ok {
+			match[varname] = capturedValue(v, ValueTypeKV)
+			continue
 		}
-		match[name] = v
+		match[name] = capturedValue(v, hints[name])
 	}
 	return match
 }
+
+func capturedValue(v []string, hint ValueType) Value {
+	switch {
+	case hint == ValueTypeKV && len(v)%2 == 0:
+		return Value{T: ValueTypeKV, V: v}
+	case hint == ValueTypeList:
+		return Value{T: ValueTypeList, V: v}
+	case len(v) == 1:
+		return Value{T: ValueTypeString, V: v}
+	default:
+		return Value{T: ValueTypeList, V: v}
+	}
+}
+
+// kvCapSuffix is the suffix of the names of the captures of a variable
+// with the explode modifier matched as an associative array without a
+// hint. It never appears in variable names.
+const kvCapSuffix = "="
+
+func kvCapName(varname string) string {
+	return varname + kvCapSuffix
+}
+
+// kvCapVarname returns the variable name of a capture named by kvCapName.
+func kvCapVarname(name string) (string, bool) {
+	if !strings.HasSuffix(name, kvCapSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(name, kvCapSuffix), true
+}
+
+// queryCapName is the name of the capture of query parameters that
+// matchQuery handles. It never conflicts with variable names.
+const queryCapName = "?"
+
+// splitQueryExprs splits exprs into the leading part and the trailing
+// {?...} and {&...} expressions.
+func splitQueryExprs(exprs []template) ([]template, []*expression) {
+	i := len(exprs)
+	for i > 0 {
+		expr, ok := exprs[i-1].(*expression)
+		if !ok || (expr.op != OpQuestion && expr.op != OpAmpersand) {
+			break
+		}
+		i--
+	}
+
+	query := make([]*expression, 0, len(exprs)-i)
+	for _, expr := range exprs[i:] {
+		query = append(query, expr.(*expression))
+	}
+	return exprs[:i], query
+}
+
+// matchQuery matches the query parameters in s against the variables of
+// the query expressions regardless of their order, and returns the
+// decoded elements captured for each variable.
+func matchQuery(s string, query []*expression, opts MatchOptions) (map[string][]string, bool) {
+	elems := make(map[string][]string)
+	if s == "" {
+		return elems, true
+	}
+	if s[:1] != query[0].first {
+		return nil, false
+	}
+
+	specs := make(map[string]Varspec)
+	rest := opts.ExtraParams // variable that collects unknown parameters
+	for _, expr := range query {
+		for _, spec := range expr.vars {
+			if _, ok := specs[spec.name]; !ok {
+				specs[spec.name] = spec
+			}
+			if rest == "" && spec.explode && opts.Hints[spec.name] == ValueTypeKV {
+				rest = spec.name
+			}
+		}
+	}
+
+	for _, param := range strings.Split(s[1:], "&") {
+		if param == "" {
+			continue
+		}
+		name, value := param, ""
+		if eq := strings.IndexByte(param, '='); eq >= 0 {
+			name, value = param[:eq], param[eq+1:]
+		}
+
+		spec, ok := specs[name]
+		if !ok || (spec.explode && opts.Hints[spec.name] == ValueTypeKV) {
+			if rest == "" {
+				return nil, false
+			}
+			elems[rest] = append(elems[rest], pctDecode(name), pctDecode(value))
+			continue
+		}
+
+		specname := specName(spec)
+		if _, ok := elems[specname]; ok && !spec.explode {
+			return nil, false
+		}
+		values := []string{value}
+		if !spec.explode {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if !isQueryValue(v, spec.maxlen) {
+				return nil, false
+			}
+			elems[specname] = append(elems[specname], pctDecode(v))
+		}
+		if !spec.explode && opts.Hints[spec.name] == ValueTypeKV && len(values)%2 != 0 {
+			return nil, false
+		}
+	}
+	return elems, true
+}
+
+// isQueryValue reports whether s consists of unreserved characters and
+// pct-encoded triplets, and has at most maxlen characters unless maxlen
+// is less than 1. Triplets of a UTF-8 sequence are counted as one
+// character.
+func isQueryValue(s string, maxlen int) bool {
+	n := 0
+	for i := 0; i < len(s); n++ {
+		if s[i] == '%' {
+			if len(s)-i < 3 || !ishex(s[i+1]) || !ishex(s[i+2]) {
+				return false
+			}
+			if maxlen < 1 {
+				i += 3
+				continue
+			}
+			var cont int
+			switch pctLeadClass(rune(s[i+1])) {
+			case runeClassPctLead2:
+				cont = 1
+			case runeClassPctLead3:
+				cont = 2
+			case runeClassPctLead4:
+				cont = 3
+			case runeClassPctCont:
+				return false
+			}
+			i += 3
+			for ; cont > 0; cont-- {
+				if len(s)-i < 3 || s[i] != '%' ||
+					pctLeadClass(rune(s[i+1])) != runeClassPctCont || !ishex(s[i+2]) {
+					return false
+				}
+				i += 3
+			}
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.Is(rangeUnreserved, r) {
+			return false
+		}
+		i += size
+	}
+	return maxlen < 1 || n <= maxlen
+}
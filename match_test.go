@@ -60,9 +60,7 @@ func TestTemplate_Match(t *testing.T) {
 					t.Errorf("%d: expected %#v, but got %#v", i, expected, actual)
 					continue
 				}
-				if v := expected.V[0]; len(v) > maxlen {
-					expected.V = []string{v[:maxlen]}
-				}
+				expected.V = []string{prefix(expected.V[0], maxlen)}
 			} else {
 				expected = testExpressionExpandVarMap[name]
 			}
@@ -93,3 +91,184 @@ func TestTemplate_NotMatch(t *testing.T) {
 		t.Errorf("must not match")
 	}
 }
+
+func TestTemplate_MatchWithHints(t *testing.T) {
+	hints := map[string]ValueType{
+		"keys": ValueTypeKV,
+		"list": ValueTypeList,
+	}
+	for _, raw := range []string{
+		"{keys}", "{keys*}", "X{.keys}", "X{.keys*}", "{/keys}", "{/keys*}",
+		"{;keys}", "{;keys*}", "{?keys}", "{?keys*}", "{&keys}", "{&keys*}",
+	} {
+		tmpl := MustNew(raw)
+		expansion, err := tmpl.Expand(testExpressionExpandVarMap)
+		if err != nil {
+			t.Errorf("unexpected error on %q: %#v", raw, err)
+			continue
+		}
+
+		match := tmpl.MatchWithHints(expansion, hints)
+		expected := testExpressionExpandVarMap["keys"]
+		if actual := match.Get("keys"); actual.T != expected.T || fmt.Sprint(actual.V) != fmt.Sprint(expected.V) {
+			t.Errorf("on %q: expected %#v, but got %#v", raw, expected, actual)
+		}
+	}
+
+	match := MustNew("{/list}").MatchWithHints("/red", hints)
+	if actual := match.Get("list"); actual.T != ValueTypeList || len(actual.V) != 1 || actual.V[0] != "red" {
+		t.Errorf("expected list, but got %#v", actual)
+	}
+}
+
+func TestTemplate_Match_KVWithoutHints(t *testing.T) {
+	for _, c := range []struct {
+		raw       string
+		expansion string
+		expected  Value
+	}{
+		{"{?keys*}", "?a=1", KV("a", "1")},
+		{"{?keys*}", "?a=1&b=", KV("a", "1", "b", "")},
+		{"{/keys*}", "/a=1/b=2", KV("a", "1", "b", "2")},
+		{"{?keys*}", "?keys=a&keys=b", List("a", "b")},
+		{"{/keys*}", "/a/b", List("a", "b")},
+	} {
+		match := MustNew(c.raw).Match(c.expansion)
+		if actual := match.Get("keys"); actual.T != c.expected.T || fmt.Sprint(actual.V) != fmt.Sprint(c.expected.V) {
+			t.Errorf("on %q: expected %#v against %q, but got %#v", c.raw, c.expected, c.expansion, match)
+		}
+	}
+}
+
+func ExampleTemplate_MatchWithOptions() {
+	tmpl := MustNew("/search{?q,page}")
+	match := tmpl.MatchWithOptions("/search?page=2&q=cat&lang=en", MatchOptions{
+		UnorderedQuery: true,
+		ExtraParams:    "extra",
+	})
+	if match == nil {
+		fmt.Println("not matched")
+		return
+	}
+
+	fmt.Printf("q is %q\n", match.Get("q").String())
+	fmt.Printf("page is %q\n", match.Get("page").String())
+	fmt.Printf("extra is %q\n", match.Get("extra").KV())
+
+	// Output:
+	// q is "cat"
+	// page is "2"
+	// extra is ["lang" "en"]
+}
+
+func TestTemplate_MatchWithOptions_UnorderedQuery(t *testing.T) {
+	opts := MatchOptions{
+		UnorderedQuery: true,
+		Hints:          map[string]ValueType{"keys": ValueTypeKV},
+	}
+	for _, c := range []struct {
+		raw       string
+		expansion string
+		expected  Values
+	}{
+		{"/search{?q,page}", "/search", Values{}},
+		{"/search{?q,page}", "/search?", Values{}},
+		{"/search{?q,page}", "/search?page=2&q=x", Values{"q": String("x"), "page": String("2")}},
+		{"/search{?q,page}", "/search?page=2&q=x&lang=en", nil},
+		{"/search{?q,page}", "/search?q=x&q=y", nil},
+		{"/search{?q,page}", "/search&q=x", nil},
+		{"/search{?q:3}", "/search?q=abcd", nil},
+		{"/search{?q:3}", "/search?q=a%20c", Values{"q:3": String("a c")}},
+		{"/search{?q}", "/search?q=a/c", nil},
+		{"/{id}{?list}{&tags*}", "/1?tags=b&list=r,g&tags=a", Values{"id": String("1"), "list": List("r", "g"), "tags": List("b", "a")}},
+		{"/search?fixed=yes{&q}", "/search?fixed=yes&q=x", Values{"q": String("x")}},
+		{"/search{?q,keys*}", "/search?b=2&q=x&a=1", Values{"q": String("x"), "keys": KV("b", "2", "a", "1")}},
+	} {
+		match := MustNew(c.raw).MatchWithOptions(c.expansion, opts)
+		if (match == nil) != (c.expected == nil) {
+			t.Errorf("on %q: expected %#v, but got %#v", c.expansion, c.expected, match)
+			continue
+		}
+		if len(match) != len(c.expected) {
+			t.Errorf("on %q: expected %#v, but got %#v", c.expansion, c.expected, match)
+			continue
+		}
+		for name, expected := range c.expected {
+			if actual := match.Get(name); actual.T != expected.T || fmt.Sprint(actual.V) != fmt.Sprint(expected.V) {
+				t.Errorf("on %q: %s: expected %#v, but got %#v", c.expansion, name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestTemplate_MatchWithOptions_ExtraParams(t *testing.T) {
+	opts := MatchOptions{
+		UnorderedQuery: true,
+		Hints:          map[string]ValueType{"keys": ValueTypeKV},
+		ExtraParams:    "extra",
+	}
+	for _, c := range []struct {
+		raw       string
+		expansion string
+		expected  Values
+	}{
+		{"/search{?q}", "/search?lang=en&q=x", Values{"q": String("x"), "extra": KV("lang", "en")}},
+		{"/search{?q,keys*}", "/search?b=2&q=x&a=1", Values{"q": String("x"), "extra": KV("b", "2", "a", "1")}},
+		{"/search{?q,list*}", "/search?list=a&b=2&list=c", Values{"list": List("a", "c"), "extra": KV("b", "2")}},
+	} {
+		match := MustNew(c.raw).MatchWithOptions(c.expansion, opts)
+		if len(match) != len(c.expected) {
+			t.Errorf("on %q: expected %#v, but got %#v", c.expansion, c.expected, match)
+			continue
+		}
+		for name, expected := range c.expected {
+			if actual := match.Get(name); actual.T != expected.T || fmt.Sprint(actual.V) != fmt.Sprint(expected.V) {
+				t.Errorf("on %q: %s: expected %#v, but got %#v", c.expansion, name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestTemplate_NotMatch_TrailingRune(t *testing.T) {
+	for _, c := range []struct {
+		raw       string
+		expansion string
+	}{
+		{"/a", "/ab"},
+		{"/a{/path}", "/ax"},
+		{"{/path*}", "x"},
+	} {
+		if match := MustNew(c.raw).Match(c.expansion); match != nil {
+			t.Errorf("%q must not match %q: %#v", c.raw, c.expansion, match)
+		}
+	}
+}
+
+func TestTemplate_Match_PrefixOfUTF8(t *testing.T) {
+	for _, c := range []struct {
+		raw   string
+		input string
+		match bool
+	}{
+		{"{x:2}", "%E6%97%A5%E6%9C%AC", true},
+		{"{x:2}", "%E6%97%A5%E6%9C%AC%E8%AA%9E", false},
+		{"{x:2}", "a%C3%A9", true},
+		{"{x:2}", "%E6%97", false},
+		{"{x:2}", "%97%A5", false},
+		{"{x}", "%E6%97", true},
+		{"{?x:1}", "?x=%F0%9F%8D%A3", true},
+		{"{?x:1}", "?x=%F0%9F%8D%A3%F0%9F%8D%A3", false},
+	} {
+		tmpl := MustNew(c.raw)
+		if match := tmpl.Match(c.input) != nil; match != c.match {
+			t.Errorf("on %q: Match returns %t against %q", c.raw, match, c.input)
+		}
+		opts := MatchOptions{UnorderedQuery: true}
+		if match := tmpl.MatchWithOptions(c.input, opts) != nil; match != c.match {
+			t.Errorf("on %q: MatchWithOptions returns %t against %q", c.raw, match, c.input)
+		}
+		if match := tmpl.Regexp().MatchString(c.input); match != c.match {
+			t.Errorf("on %q: regexp returns %t against %q", c.raw, match, c.input)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// accepts reports whether op, which is opRune or opRuneClass, consumes r.
+func (op *progOp) accepts(r rune) bool {
+	if op.code == opRune {
+		return op.r == r
+	}
+	return op.rc.contains(r)
+}
+
+// capEvent is a captured position, linked to the previous one.
+type capEvent struct {
+	name string
+	pos  int
+	prev *capEvent
+}
+
+// backtracker enumerates all paths of a prog matching the input, in the
+// order of the priority the matcher gives to them.
+type backtracker struct {
+	prog  *prog
+	input string
+	yield func(*capEvent) bool
+
+	// visited holds the pcs visited since the last rune was consumed, to
+	// cut loops that consume nothing.
+	visited []bool
+
+	// failed holds the positions of the ops consuming runes that are known
+	// not to reach the end of the match, keyed by failKey. yields counts
+	// the paths that have reached the end.
+	failed map[int]bool
+	yields int
+}
+
+// failKey returns the key of failed for pc and pos.
+func (b *backtracker) failKey(pc uint32, pos int) int {
+	return int(pc)*(len(b.input)+1) + pos
+}
+
+func (b *backtracker) run(pc uint32, pos int, cap *capEvent) bool {
+	if b.visited[pc] {
+		return true
+	}
+	b.visited[pc] = true
+	defer func() { b.visited[pc] = false }()
+
+	op := &b.prog.op[pc]
+	switch op.code {
+	default:
+		panic("unhandled opcode")
+	case opRune, opRuneClass:
+		if pos >= len(b.input) {
+			return true
+		}
+		r, size := utf8.DecodeRuneInString(b.input[pos:])
+		if !op.accepts(r) {
+			return true
+		}
+		// The paths after a rune is consumed do not depend on visited,
+		// so the failure is memoized.
+		key := b.failKey(pc, pos)
+		if b.failed[key] {
+			return true
+		}
+		visited, yields := b.visited, b.yields
+		b.visited = make([]bool, len(visited))
+		ok := b.run(pc+1, pos+size, cap)
+		b.visited = visited
+		if ok && b.yields == yields {
+			b.failed[key] = true
+		}
+		return ok
+	case opLineBegin:
+		if pos == 0 {
+			return b.run(pc+1, pos, cap)
+		}
+		return true
+	case opLineEnd:
+		if pos == len(b.input) {
+			return b.run(pc+1, pos, cap)
+		}
+		return true
+	case opCapStart, opCapEnd:
+		return b.run(pc+1, pos, &capEvent{name: op.name, pos: pos, prev: cap})
+	case opSplit, opJmpIfNotDefined, opJmpIfNotFirst:
+		return b.run(pc+1, pos, cap) && b.run(op.i, pos, cap)
+	case opJmpIfNotEmpty:
+		return b.run(op.i, pos, cap) && b.run(pc+1, pos, cap)
+	case opJmp:
+		return b.run(op.i, pos, cap)
+	case opNoop:
+		return b.run(pc+1, pos, cap)
+	case opEnd:
+		b.yields++
+		return b.yield(cap)
+	}
+}
+
+// valuesKey returns a string that identifies values. Variables of empty
+// strings are regarded as undefined.
+func valuesKey(values Values) string {
+	names := make([]string, 0, len(values))
+	for name, v := range values {
+		if v.T == ValueTypeString && v.V[0] == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		v := values[name]
+		b.WriteString(name)
+		b.WriteByte(byte(v.T))
+		for _, s := range v.V {
+			b.WriteString(s)
+			b.WriteByte(0)
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// expandValues returns values to expand the template with, where each
+// variable captured only with prefix modifiers takes its longest capture.
+func expandValues(values Values) Values {
+	vars := make(Values, len(values))
+	for name, v := range values {
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[:i]
+			if _, ok := values[name]; ok {
+				continue
+			}
+			if w, ok := vars[name]; ok && utf8.RuneCountInString(w.String()) >= utf8.RuneCountInString(v.String()) {
+				continue
+			}
+		}
+		vars[name] = v
+	}
+	return vars
+}
+
+// MatchAll is like Match, but returns all the distinct sets of variables
+// with which the template expands to expansion, where variables of empty
+// strings are regarded as undefined. Unlike Match, it does not accept the
+// separators and commas that Expand never produces.
+// If n >= 0, MatchAll returns at most n sets.
+func (tmpl *Template) MatchAll(expansion string, n int) []Values {
+	prog := tmpl.strictProg()
+
+	var ret []Values
+	seen := make(map[string]bool)
+	b := backtracker{
+		prog:    prog,
+		input:   expansion,
+		visited: make([]bool, len(prog.op)),
+		failed:  make(map[int]bool),
+	}
+	b.yield = func(e *capEvent) bool {
+		cap := make(map[string][]int)
+		for ; e != nil; e = e.prev {
+			cap[e.name] = append(cap[e.name], e.pos)
+		}
+		for _, indices := range cap {
+			for i, j := 0, len(indices)-1; i < j; i, j = i+1, j-1 {
+				indices[i], indices[j] = indices[j], indices[i]
+			}
+		}
+
+		values := captureValues(expansion, cap, nil)
+		if s, err := tmpl.Expand(expandValues(values)); err != nil || s != expansion {
+			return true
+		}
+		if key := valuesKey(values); !seen[key] {
+			seen[key] = true
+			ret = append(ret, values)
+		}
+		return n < 0 || len(ret) < n
+	}
+	if n != 0 {
+		b.run(0, 0, nil)
+	}
+	return ret
+}
+
+// closureEnd is an op that the closure of a pc stops at, with the
+// captures made on the way, keyed by a canonical string.
+type closureEnd struct {
+	pc   uint32
+	caps string
+}
+
+// capCount is the number of captures made for a variable in a closure.
+// empty reports whether they are exactly the start and end of one empty
+// value.
+type capCount struct {
+	n     int
+	empty bool
+}
+
+// closures computes, for each pc, the ops reachable without consuming any
+// rune that either consume a rune or end the match.
+type closures struct {
+	prog  *prog
+	cache map[uint32][]closureEnd
+	caps  map[string]map[string]capCount
+}
+
+func (c *closures) get(pc uint32) []closureEnd {
+	if ends, ok := c.cache[pc]; ok {
+		return ends
+	}
+
+	seen := make(map[closureEnd]bool)
+	var ends []closureEnd
+	visited := make([]bool, len(c.prog.op))
+	caps := make(map[string]int)
+	// open counts the captures started in the closure, and empty the ones
+	// which also end in it.
+	open := make(map[string]int)
+	empty := make(map[string]int)
+	var walk func(pc uint32)
+	walk = func(pc uint32) {
+		if visited[pc] {
+			return
+		}
+		visited[pc] = true
+		defer func() { visited[pc] = false }()
+
+		op := &c.prog.op[pc]
+		switch op.code {
+		default:
+			panic("unhandled opcode")
+		case opRune, opRuneClass, opEnd:
+			counts := make(map[string]capCount)
+			for name, n := range caps {
+				if n > 0 {
+					counts[name] = capCount{n: n, empty: n == 2 && empty[name] == 1}
+				}
+			}
+			key := capsKey(counts)
+			c.caps[key] = counts
+			end := closureEnd{pc: pc, caps: key}
+			if !seen[end] {
+				seen[end] = true
+				ends = append(ends, end)
+			}
+		case opLineBegin, opLineEnd, opNoop:
+			// opLineBegin is only at the start, and opLineEnd is only
+			// followed by opEnd.
+			walk(pc + 1)
+		case opCapStart:
+			caps[op.name]++
+			open[op.name]++
+			walk(pc + 1)
+			open[op.name]--
+			caps[op.name]--
+		case opCapEnd:
+			caps[op.name]++
+			if open[op.name] > 0 {
+				open[op.name]--
+				empty[op.name]++
+				walk(pc + 1)
+				empty[op.name]--
+				open[op.name]++
+			} else {
+				walk(pc + 1)
+			}
+			caps[op.name]--
+		case opSplit, opJmpIfNotDefined, opJmpIfNotFirst, opJmpIfNotEmpty:
+			walk(pc + 1)
+			walk(op.i)
+		case opJmp:
+			walk(op.i)
+		}
+	}
+	walk(pc)
+
+	c.cache[pc] = ends
+	return ends
+}
+
+func capsKey(counts map[string]capCount) string {
+	var b strings.Builder
+	for _, name := range sortedNames(counts) {
+		b.WriteString(name)
+		b.WriteByte(0)
+		b.WriteString(strconv.Itoa(counts[name].n))
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func sortedNames(counts map[string]capCount) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nameSet is a set of variable names, encoded as a string to be a part of
+// a map key.
+type nameSet string
+
+func (s nameSet) has(name string) bool {
+	return strings.Contains(string(s), "\x00"+name+"\x00")
+}
+
+func (s nameSet) add(name string) nameSet {
+	if s == "" {
+		return nameSet("\x00" + name + "\x00")
+	}
+	if s.has(name) {
+		return s
+	}
+	names := append(strings.Split(string(s[1:len(s)-1]), "\x00"), name)
+	sort.Strings(names)
+	return nameSet("\x00" + strings.Join(names, "\x00") + "\x00")
+}
+
+// commonRune returns a rune consumed by both op1 and op2, or false if
+// there is none.
+func commonRune(op1, op2 *progOp) (rune, bool) {
+	if op1.code == opRune {
+		return op1.r, op2.accepts(op1.r)
+	}
+	if op2.code == opRune {
+		return op2.r, op1.accepts(op2.r)
+	}
+	// rune classes consist of ASCII characters
+	for r := rune(0); r < utf8.RuneSelf; r++ {
+		if op1.accepts(r) && op2.accepts(r) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// IsAmbiguous reports whether MatchAll returns more than one set of
+// variables for some input.
+//
+// IsAmbiguous runs two paths of the program MatchAll uses in lockstep over
+// every input, and looks for the ones that capture at different positions
+// but both reach the end of the match. A variable that one path captures
+// as an empty string and the other never captures is regarded as the
+// same. It reports true if MatchAll returns more than one set for the
+// input of such paths.
+func (tmpl *Template) IsAmbiguous() bool {
+	prog := tmpl.strictProg()
+	c := closures{
+		prog:  prog,
+		cache: make(map[uint32][]closureEnd),
+		caps:  make(map[string]map[string]capCount),
+	}
+
+	// Until the paths diverge, captured holds the variables both have
+	// captured alike, and deferred the ones only either has captured as an
+	// empty string.
+	type state struct {
+		pc1, pc2 uint32
+		diverged bool
+		captured nameSet
+		deferred nameSet
+	}
+	// prev maps a state to the one it follows and the rune consumed
+	// between them, to build the input of the paths.
+	type edge struct {
+		from state
+		r    rune
+	}
+	prev := make(map[state]edge)
+	input := func(s state) string {
+		var runes []rune
+		for {
+			e, ok := prev[s]
+			if !ok {
+				break
+			}
+			runes = append(runes, e.r)
+			s = e.from
+		}
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes)
+	}
+
+	seen := make(map[state]bool)
+	queue := []state{{pc1: 0, pc2: 0}}
+	seen[queue[0]] = true
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		for _, e1 := range c.get(s.pc1) {
+			for _, e2 := range c.get(s.pc2) {
+				next := s
+				if !s.diverged {
+					next.diverged = !c.merge(&next.captured, &next.deferred, e1.caps, e2.caps)
+				}
+				if next.diverged {
+					next.captured, next.deferred = "", ""
+				}
+
+				op1, op2 := &prog.op[e1.pc], &prog.op[e2.pc]
+				if op1.code == opEnd || op2.code == opEnd {
+					if op1.code == opEnd && op2.code == opEnd && next.diverged &&
+						len(tmpl.MatchAll(input(s), 2)) > 1 {
+						return true
+					}
+					continue
+				}
+				r, ok := commonRune(op1, op2)
+				if !ok {
+					continue
+				}
+				next.pc1, next.pc2 = e1.pc+1, e2.pc+1
+				if !seen[next] {
+					seen[next] = true
+					prev[next] = edge{from: s, r: r}
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return false
+}
+
+// merge updates captured and deferred with the captures two paths make in
+// closures at the same position, and reports whether the paths still
+// capture the same values.
+func (c *closures) merge(captured, deferred *nameSet, caps1, caps2 string) bool {
+	counts1, counts2 := c.caps[caps1], c.caps[caps2]
+	for _, counts := range []map[string]capCount{counts1, counts2} {
+		for _, name := range sortedNames(counts) {
+			n1, n2 := counts1[name], counts2[name]
+			switch {
+			case deferred.has(name):
+				// the empty string is followed by another value
+				return false
+			case n1.n == n2.n:
+				*captured = captured.add(name)
+			case captured.has(name):
+				return false
+			case n1.empty && n2.n == 0, n2.empty && n1.n == 0:
+				*deferred = deferred.add(name)
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
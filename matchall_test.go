@@ -0,0 +1,112 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ExampleTemplate_MatchAll() {
+	tmpl := MustNew("/files/{dir}{name}")
+	for _, match := range tmpl.MatchAll("/files/ab", -1) {
+		fmt.Printf("dir=%q name=%q\n", match.Get("dir").String(), match.Get("name").String())
+	}
+	fmt.Println(tmpl.IsAmbiguous())
+
+	// Output:
+	// dir="ab" name=""
+	// dir="a" name="b"
+	// dir="" name="ab"
+	// true
+}
+
+func TestTemplate_MatchAll(t *testing.T) {
+	for _, c := range []struct {
+		raw      string
+		input    string
+		n        int
+		expected int
+	}{
+		{"{a}{b}", "xyz", -1, 4},
+		{"{a}{b}", "xyz", 2, 2},
+		{"{a}{b}", "xyz", 0, 0},
+		{"{x,y}", "a", -1, 2},
+		{"/{a}/{b}", "/x/y", -1, 1},
+		{"/{a}/{b}", "/x", -1, 0},
+		{"{?x,y}", "?x=1&y=2", -1, 1},
+		{"{x,y}", "a,b,c", -1, 4}, // not x=a and y=",b,c"
+		{"{?q}", "?q=a,", -1, 1},  // a list of a and "", not "a"
+		{"{?q}", "?q=a&", -1, 0},
+	} {
+		tmpl := MustNew(c.raw)
+		all := tmpl.MatchAll(c.input, c.n)
+		if len(all) != c.expected {
+			t.Errorf("on %q: expected %d matches against %q, but got %v", c.raw, c.expected, c.input, all)
+			continue
+		}
+		for _, values := range all {
+			if s, err := tmpl.Expand(expandValues(values)); err != nil || s != c.input {
+				t.Errorf("on %q: %v expands to %q, not %q", c.raw, values, s, c.input)
+			}
+		}
+	}
+}
+
+func TestTemplate_MatchAll_NotMatch(t *testing.T) {
+	// Without memoization, the backtracker takes exponential time to fail.
+	tmpl := MustNew("{a}{b}{c}{d}{e}{f}")
+	input := strings.Repeat("x", 200) + "/"
+	for _, n := range []int{1, -1} {
+		if all := tmpl.MatchAll(input, n); len(all) != 0 {
+			t.Errorf("expected no matches, but got %v", all)
+		}
+	}
+}
+
+func TestTemplate_IsAmbiguous(t *testing.T) {
+	for _, c := range []struct {
+		raw      string
+		expected bool
+	}{
+		{"{var}", false},
+		{"O{empty}X", false},
+		{"/{a}/{b}", false},
+		{"{/list*}", false},
+		{"{?x,y}", false},
+		{"{?q}", false},
+		{"{?q,page}", false},
+		{"/search{?q,page,lang}", false},
+		{"{?q}{&p}", false},
+		{"{/a,b}", true}, // "/x" is a=x, or b=x with a undefined
+		{"https://example.com/dictionary/{term:1}/{term}", false},
+		{"{a}{b}", true},
+		{"{x,y}", true},
+		{"{+path}/{x}", true},
+		{"X{.list*}", true},
+		{"/files/{dir}{.ext}", true},
+	} {
+		if actual := MustNew(c.raw).IsAmbiguous(); actual != c.expected {
+			t.Errorf("on %q: expected %t, but got %t", c.raw, c.expected, actual)
+		}
+	}
+}
+
+func TestTemplate_IsAmbiguous_MatchAll(t *testing.T) {
+	for _, c := range testTemplateCases {
+		tmpl := MustNew(c.raw)
+		if tmpl.IsAmbiguous() {
+			continue
+		}
+		for _, s := range mutations(c.expected) {
+			if all := tmpl.MatchAll(s, 2); len(all) > 1 {
+				t.Errorf("on %q: not ambiguous, but got %v against %q", c.raw, all, s)
+			}
+		}
+	}
+}
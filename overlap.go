@@ -0,0 +1,73 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"strings"
+)
+
+// Overlaps reports whether some URI is matched by both t1 and t2, and
+// returns the shortest one as an example if so.
+//
+// Overlaps runs the programs of t1 and t2 in lockstep, which is the
+// intersection of the automata they compile into.
+func Overlaps(t1, t2 *Template) (bool, string) {
+	c1 := closures{
+		prog:  t1.compile(nil, false),
+		cache: make(map[uint32][]closureEnd),
+		caps:  make(map[string]map[string]capCount),
+	}
+	c2 := closures{
+		prog:  t2.compile(nil, false),
+		cache: make(map[uint32][]closureEnd),
+		caps:  make(map[string]map[string]capCount),
+	}
+
+	type state struct {
+		pc1, pc2 uint32
+	}
+	// step is a state reached by consuming r from the step at prev.
+	type step struct {
+		state
+		r    rune
+		prev int
+	}
+	steps := []step{{prev: -1}}
+	seen := map[state]bool{{}: true}
+	for i := 0; i < len(steps); i++ {
+		s := steps[i]
+		for _, e1 := range c1.get(s.pc1) {
+			for _, e2 := range c2.get(s.pc2) {
+				op1, op2 := &c1.prog.op[e1.pc], &c2.prog.op[e2.pc]
+				if op1.code == opEnd || op2.code == opEnd {
+					if op1.code == opEnd && op2.code == opEnd {
+						var runes []rune
+						for j := i; j > 0; j = steps[j].prev {
+							runes = append(runes, steps[j].r)
+						}
+						var b strings.Builder
+						for j := len(runes) - 1; j >= 0; j-- {
+							b.WriteRune(runes[j])
+						}
+						return true, b.String()
+					}
+					continue
+				}
+				r, ok := commonRune(op1, op2)
+				if !ok {
+					continue
+				}
+				next := state{e1.pc + 1, e2.pc + 1}
+				if !seen[next] {
+					seen[next] = true
+					steps = append(steps, step{state: next, r: r, prev: i})
+				}
+			}
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleOverlaps() {
+	fmt.Println(Overlaps(MustNew("/users/{id}"), MustNew("/users/me{?x}")))
+	fmt.Println(Overlaps(MustNew("/users/{id}"), MustNew("/users/{id}/posts")))
+
+	// Output:
+	// true /users/me
+	// false
+}
+
+func TestOverlaps(t *testing.T) {
+	for _, c := range []struct {
+		t1       string
+		t2       string
+		expected bool
+	}{
+		{"/users/{id}", "/users/me{?x}", true},
+		{"/users/{id}", "/users/{id}/posts", false},
+		{"/users/{id}", "/posts/{id}", false},
+		{"{/path*}", "/a/b", true},
+		{"/search{?q}", "/search{?lang}", true},
+		{"/search?q={q}", "/search{?lang}", false},
+		{"/files{/name:2}", "/files/abc", false},
+		{"/files{/name:3}", "/files/abc", true},
+		{"/files{/name:3}", "/files/%E6%97%A5", true},
+		{"{+path}/here", "/a/{b}", true},
+		{"{x}", "{y}", true},
+	} {
+		t1, t2 := MustNew(c.t1), MustNew(c.t2)
+		for _, pair := range [][2]*Template{{t1, t2}, {t2, t1}} {
+			actual, example := Overlaps(pair[0], pair[1])
+			if actual != c.expected {
+				t.Errorf("on %q and %q: expected %t, but got %t", pair[0].Raw(), pair[1].Raw(), c.expected, actual)
+				continue
+			}
+			if !actual {
+				continue
+			}
+			for _, tmpl := range pair {
+				if tmpl.Match(example) == nil {
+					t.Errorf("on %q and %q: example %q does not match %q", pair[0].Raw(), pair[1].Raw(), example, tmpl.Raw())
+				}
+			}
+		}
+	}
+}
@@ -12,19 +12,41 @@ import (
 	"unicode/utf8"
 )
 
-type parseOp int
+// Operator represents an expression operator defined in RFC 6570 § 2.2.
+type Operator int
 
 const (
-	parseOpSimple parseOp = iota
-	parseOpPlus
-	parseOpCrosshatch
-	parseOpDot
-	parseOpSlash
-	parseOpSemicolon
-	parseOpQuestion
-	parseOpAmpersand
+	OpSimple     Operator = iota // {var}
+	OpPlus                       // {+var}
+	OpCrosshatch                 // {#var}
+	OpDot                        // {.var}
+	OpSlash                      // {/var}
+	OpSemicolon                  // {;var}
+	OpQuestion                   // {?var}
+	OpAmpersand                  // {&var}
+	opLast
 )
 
+var operatorNames = []string{
+	"",
+	"+",
+	"#",
+	".",
+	"/",
+	";",
+	"?",
+	"&",
+}
+
+// String returns the operator character as written in a template.
+// It returns an empty string for OpSimple.
+func (op Operator) String() string {
+	if 0 <= op && op < opLast {
+		return operatorNames[op]
+	}
+	return ""
+}
+
 var (
 	rangeVarchar = &unicode.RangeTable{
 		R16: []unicode.Range16{
@@ -77,11 +99,16 @@ type parser struct {
 	r     string
 	start int
 	stop  int
+	expr  int
 	state parseState
 }
 
-func (p *parser) errorf(i rune, format string, a ...interface{}) error {
-	return fmt.Errorf("%s: %s%s", fmt.Sprintf(format, a...), p.r[0:p.stop], string(i))
+func (p *parser) error(offset int, err error) error {
+	exprPos := -1
+	if p.state != parseStateDefault {
+		exprPos = p.expr
+	}
+	return newParseError(p.r, offset, exprPos, err)
 }
 
 func (p *parser) rune() (rune, int) {
@@ -123,14 +150,14 @@ func (p *parser) parseURITemplate() (*Template, error) {
 		if r == utf8.RuneError {
 			if size == 0 {
 				if p.state != parseStateDefault {
-					return nil, p.errorf('_', "incomplete expression")
+					return nil, p.error(p.stop, ErrIncompleteExpression)
 				}
 				if p.start < p.stop {
 					tmpl.exprs = append(tmpl.exprs, literals(p.r[p.start:p.stop]))
 				}
 				return &tmpl, nil
 			}
-			return nil, p.errorf('_', "invalid UTF-8 sequence")
+			return nil, p.error(p.stop, ErrInvalidUTF8)
 		}
 
 		switch p.state {
@@ -140,7 +167,8 @@ func (p *parser) parseURITemplate() (*Template, error) {
 				if stop := p.stop - size; stop > p.start {
 					tmpl.exprs = append(tmpl.exprs, literals(p.r[p.start:stop]))
 				}
-				exp = &expression{}
+				p.expr = p.stop - size
+				exp = &expression{pos: p.expr}
 				tmpl.exprs = append(tmpl.exprs, exp)
 				p.setState(parseStateOperator)
 			case '%':
@@ -151,30 +179,30 @@ func (p *parser) parseURITemplate() (*Template, error) {
 			default:
 				if !unicode.Is(rangeLiterals, r) {
 					p.unread(r)
-					return nil, p.errorf('_', "unacceptable character (hint: use %%XX encoding)")
+					return nil, p.error(p.stop, ErrUnacceptableCharacter)
 				}
 			}
 		case parseStateOperator:
 			switch r {
 			default:
 				p.unread(r)
-				exp.op = parseOpSimple
+				exp.op = OpSimple
 			case '+':
-				exp.op = parseOpPlus
+				exp.op = OpPlus
 			case '#':
-				exp.op = parseOpCrosshatch
+				exp.op = OpCrosshatch
 			case '.':
-				exp.op = parseOpDot
+				exp.op = OpDot
 			case '/':
-				exp.op = parseOpSlash
+				exp.op = OpSlash
 			case ';':
-				exp.op = parseOpSemicolon
+				exp.op = OpSemicolon
 			case '?':
-				exp.op = parseOpQuestion
+				exp.op = OpQuestion
 			case '&':
-				exp.op = parseOpAmpersand
+				exp.op = OpAmpersand
 			case '=', ',', '!', '@', '|': // op-reserved
-				return nil, p.errorf('|', "unimplemented operator (op-reserved)")
+				return nil, p.error(p.stop-size, ErrReservedOperator)
 			}
 			p.setState(parseStateVarName)
 		case parseStateVarList:
@@ -182,23 +210,26 @@ func (p *parser) parseURITemplate() (*Template, error) {
 			case ',':
 				p.setState(parseStateVarName)
 			case '}':
+				exp.end = p.stop
 				exp.init()
 				p.setState(parseStateDefault)
 			default:
 				p.unread(r)
-				return nil, p.errorf('_', "unrecognized value modifier")
+				return nil, p.error(p.stop, ErrInvalidModifier)
 			}
 		case parseStateVarName:
 			switch r {
 			case ':', '*':
 				name := p.r[p.start : p.stop-size]
 				if !isValidVarname(name) {
-					return nil, p.errorf('|', "unacceptable variable name")
+					return nil, p.error(p.start, ErrInvalidVarname)
 				}
 				explode := r == '*'
-				exp.vars = append(exp.vars, varspec{
+				exp.vars = append(exp.vars, Varspec{
 					name:    name,
 					explode: explode,
+					pos:     p.start,
+					end:     p.stop,
 				})
 				if explode {
 					p.setState(parseStateVarList)
@@ -209,10 +240,12 @@ func (p *parser) parseURITemplate() (*Template, error) {
 				p.unread(r)
 				name := p.r[p.start:p.stop]
 				if !isValidVarname(name) {
-					return nil, p.errorf('|', "unacceptable variable name")
+					return nil, p.error(p.start, ErrInvalidVarname)
 				}
-				exp.vars = append(exp.vars, varspec{
+				exp.vars = append(exp.vars, Varspec{
 					name: name,
+					pos:  p.start,
+					end:  p.stop,
 				})
 				p.setState(parseStateVarList)
 			case '%':
@@ -222,12 +255,12 @@ func (p *parser) parseURITemplate() (*Template, error) {
 				}
 			case '.':
 				if dot := p.stop - size; dot == p.start || p.r[dot-1] == '.' {
-					return nil, p.errorf('|', "unacceptable variable name")
+					return nil, p.error(p.stop-size, ErrInvalidVarname)
 				}
 			default:
 				if !unicode.Is(rangeVarchar, r) {
 					p.unread(r)
-					return nil, p.errorf('_', "unacceptable variable name")
+					return nil, p.error(p.stop, ErrInvalidVarname)
 				}
 			}
 		case parseStatePrefix:
@@ -236,19 +269,20 @@ func (p *parser) parseURITemplate() (*Template, error) {
 			case '0' <= r && r <= '9':
 				spec.maxlen *= 10
 				spec.maxlen += int(r - '0')
+				spec.end = p.stop
 				if spec.maxlen == 0 || spec.maxlen > 9999 {
-					return nil, p.errorf('|', "max-length must be (0, 9999]")
+					return nil, p.error(p.stop-size, ErrInvalidMaxlen)
 				}
 			default:
 				p.unread(r)
 				if spec.maxlen == 0 {
-					return nil, p.errorf('_', "max-length must be (0, 9999]")
+					return nil, p.error(p.stop, ErrInvalidMaxlen)
 				}
 				p.setState(parseStateVarList)
 			}
 		default:
 			p.unread(r)
-			panic(p.errorf('_', "unhandled parseState(%d)", p.state))
+			panic(fmt.Sprintf("unhandled parseState(%d)", p.state))
 		}
 	}
 }
@@ -270,7 +304,7 @@ func isValidVarname(name string) bool {
 
 func (p *parser) consumeTriplet() error {
 	if len(p.r)-p.stop < 3 || p.r[p.stop] != '%' || !ishex(p.r[p.stop+1]) || !ishex(p.r[p.stop+2]) {
-		return p.errorf('_', "incomplete pct-encodeed")
+		return p.error(p.stop, ErrInvalidPctEncoded)
 	}
 	p.stop += 3
 	return nil
@@ -0,0 +1,116 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"strings"
+)
+
+// PartialExpand expands the variables in vars and returns the template
+// with the rest of the variables left in expressions. Variables in vars
+// whose values are not valid are regarded as undefined.
+//
+// An expression may not be split into literals and expressions, since the
+// expansion of a variable can depend on whether the preceding ones are
+// defined. PartialExpand reports ErrPartialExpansion in that case, such as
+// {x,y} with only x in vars, or {?x,y} with only y.
+func (t *Template) PartialExpand(vars Values) (*Template, error) {
+	var b strings.Builder
+	for i := range t.exprs {
+		switch expr := t.exprs[i].(type) {
+		default:
+			panic("unhandled expression")
+		case literals:
+			b.WriteString(string(expr))
+		case *expression:
+			if err := expr.partialExpand(&b, vars); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Expansions consist of characters allowed in literals and
+	// pct-encoded triplets.
+	return New(b.String())
+}
+
+// continued returns the operator that expands the rest of the varspecs of
+// e after some of them are expanded, or false if there is none.
+func (e *expression) continued() (Operator, bool) {
+	switch {
+	case e.first == e.sep:
+		return e.op, true
+	case e.op == OpQuestion:
+		return OpAmpersand, true
+	}
+	return 0, false
+}
+
+// partialExpansionError reports that e cannot be expanded partially with
+// the undefined variables of e.
+func (e *expression) partialExpansionError(vars Values) error {
+	var names []string
+	for _, spec := range e.vars {
+		if _, ok := vars[spec.name]; !ok {
+			names = append(names, spec.name)
+		}
+	}
+	return &ExpandError{Varname: strings.Join(names, ","), ExprPos: e.pos, ExprEnd: e.end, Err: ErrPartialExpansion}
+}
+
+func (e *expression) partialExpand(b *strings.Builder, vars Values) error {
+	// expanded is whether a varspec has been expanded, and pending holds
+	// the varspecs left in an expression, which may expand to nothing.
+	expanded := false
+	var pending *expression
+	flush := func() {
+		if pending != nil {
+			b.WriteString(pending.String())
+			pending = nil
+		}
+	}
+	for _, spec := range e.vars {
+		value, ok := vars[spec.name]
+		if !ok {
+			if pending == nil {
+				op := e.op
+				if expanded {
+					op, ok = e.continued()
+					if !ok {
+						return e.partialExpansionError(vars)
+					}
+				}
+				pending = &expression{op: op}
+			}
+			pending.vars = append(pending.vars, spec)
+			continue
+		}
+		if !value.Valid() {
+			continue
+		}
+
+		if pending != nil && !expanded && e.first != e.sep {
+			return e.partialExpansionError(vars)
+		}
+		flush()
+		if expanded {
+			b.WriteString(e.sep)
+		} else {
+			b.WriteString(e.first)
+			expanded = true
+		}
+		if err := value.expand(b, spec, e); err != nil {
+			if err, ok := err.(*ExpandError); ok {
+				err.Varname = spec.name
+				err.ExprPos = e.pos
+				err.ExprEnd = e.end
+			}
+			return err
+		}
+	}
+	flush()
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleTemplate_PartialExpand() {
+	tmpl := MustNew("https://{tenant}.example.com/{version}/items{/id}{?q,lang}")
+
+	vars := Values{}
+	vars.Set("tenant", String("acme"))
+	vars.Set("version", String("v2"))
+	vars.Set("q", String("red apple"))
+	partial, err := tmpl.PartialExpand(vars)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(partial)
+
+	vars = Values{}
+	vars.Set("id", String("42"))
+	ret, err := partial.Expand(vars)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(ret)
+
+	// Output:
+	// https://acme.example.com/v2/items{/id}?q=red%20apple{&lang}
+	// https://acme.example.com/v2/items/42?q=red%20apple
+}
+
+func TestTemplate_PartialExpand(t *testing.T) {
+	vars := Values{
+		"a":     String("1"),
+		"list":  List("red", "green"),
+		"undef": Value{},
+	}
+	for _, c := range []struct {
+		raw      string
+		expected string
+		err      error
+	}{
+		{"{?a,b}", "?a=1{&b}", nil},
+		{"{?b,a}", "", ErrPartialExpansion},
+		{"{?b,undef,c}", "{?b,c}", nil},
+		{"{?undef,b}", "{?b}", nil},
+		{"{/a,b}", "/1{/b}", nil},
+		{"{/b,a,c}", "{/b}/1{/c}", nil},
+		{"{;a,b,list*}", ";a=1{;b};list=red;list=green", nil},
+		{"{&b,a}", "{&b}&a=1", nil},
+		{"{.a:1,b}", ".1{.b}", nil},
+		{"{a,b}", "", ErrPartialExpansion},
+		{"{b,a}", "", ErrPartialExpansion},
+		{"{+b,c}", "{+b,c}", nil},
+		{"{#a,list}", "#1,red,green", nil},
+		{"{#a,b}", "", ErrPartialExpansion},
+		{"/x/{b}/{a}", "/x/{b}/1", nil},
+	} {
+		tmpl := MustNew(c.raw)
+		actual, err := tmpl.PartialExpand(vars)
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("on %q: expected %v, but got %v", c.raw, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("on %q: unexpected error: %v", c.raw, err)
+			continue
+		}
+		if actual.Raw() != c.expected {
+			t.Errorf("on %q: expected %q, but got %q", c.raw, c.expected, actual.Raw())
+		}
+	}
+}
+
+func TestTemplate_PartialExpand_Varname(t *testing.T) {
+	vars := Values{
+		"a": String("1"),
+		"c": String("3"),
+	}
+	for _, c := range []struct {
+		raw      string
+		expected string
+	}{
+		{"{?b,a}", "b"},
+		{"{?b,d,a}", "b,d"},
+		{"{a,b}", "b"},
+		{"{a,b,c,d}", "b,d"},
+		{"{#b,a}", "b"},
+	} {
+		_, err := MustNew(c.raw).PartialExpand(vars)
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) || !errors.Is(err, ErrPartialExpansion) {
+			t.Errorf("on %q: expected %v, but got %v", c.raw, ErrPartialExpansion, err)
+			continue
+		}
+		if expandErr.Varname != c.expected {
+			t.Errorf("on %q: expected %q, but got %q", c.raw, c.expected, expandErr.Varname)
+		}
+	}
+}
+
+func TestTemplate_PartialExpand_Expand(t *testing.T) {
+	for _, c := range testTemplateCases {
+		tmpl := MustNew(c.raw)
+		names := tmpl.Varnames()
+		if len(names) > 8 {
+			names = names[:8]
+		}
+		for i := 0; i < 1<<len(names); i++ {
+			known, rest := Values{}, Values{}
+			for name, value := range testExpressionExpandVarMap {
+				rest[name] = value
+			}
+			for j, name := range names {
+				if i&(1<<j) != 0 {
+					if value, ok := testExpressionExpandVarMap[name]; ok {
+						known[name] = value
+						delete(rest, name)
+					}
+				}
+			}
+
+			partial, err := tmpl.PartialExpand(known)
+			if errors.Is(err, ErrPartialExpansion) {
+				continue
+			}
+			if err != nil {
+				t.Errorf("on %q: unexpected error: %v", c.raw, err)
+				continue
+			}
+			actual, err := partial.Expand(rest)
+			if err != nil {
+				t.Errorf("on %q: unexpected error: %v", partial.Raw(), err)
+				continue
+			}
+			if actual != c.expected {
+				t.Errorf("on %q with %v known: expected %q, but got %q from %q", c.raw, known, c.expected, actual, partial.Raw())
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RegexpDialect is a syntax of regular expressions.
+type RegexpDialect int
+
+const (
+	// RegexpRE2 is the syntax of RE2 and the regexp package.
+	RegexpRE2 RegexpDialect = iota
+	// RegexpECMAScript is the syntax of ECMAScript regular expressions
+	// with the u flag. Slashes are escaped so that the regexp can be
+	// written in a regular expression literal.
+	RegexpECMAScript
+	// RegexpPCRE is the syntax of PCRE, used by nginx among others.
+	RegexpPCRE
+)
+
+// RegexpOptions configures RegexpWithOptions and RegexpString.
+type RegexpOptions struct {
+	// Dialect is the syntax of the regexp. RegexpWithOptions ignores it.
+	Dialect RegexpDialect
+
+	// NamedGroups makes the regexp capture each varspec in a named group.
+	// A group captures the pct-encoded value of the varspec, or the whole
+	// "name=value" pairs of a varspec with the explode modifier in {;...},
+	// {?...} and {&...}. Group names are made from the varspec names;
+	// characters that cannot be used in group names are replaced by '_'.
+	NamedGroups bool
+}
+
+type regexpBuilder struct {
+	strings.Builder
+	opts RegexpOptions
+
+	// groups maps group names to varspec names
+	groups map[string]string
+}
+
+func (b *regexpBuilder) startGroup(spec Varspec) {
+	if !b.opts.NamedGroups {
+		b.WriteString("(?:")
+		return
+	}
+
+	base := groupName(spec.name)
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := b.groups[name]; !ok {
+			break
+		}
+		name = base + "_" + strconv.Itoa(i)
+	}
+	b.groups[name] = specName(spec)
+
+	if b.opts.Dialect == RegexpRE2 {
+		b.WriteString("(?P<")
+	} else {
+		b.WriteString("(?<")
+	}
+	b.WriteString(name)
+	b.WriteByte('>')
+}
+
+func (b *regexpBuilder) endGroup() {
+	b.WriteByte(')')
+}
+
+// quote escapes all regexp metacharacters in s.
+func (b *regexpBuilder) quote(s string) string {
+	s = regexp.QuoteMeta(s)
+	if b.opts.Dialect == RegexpECMAScript {
+		s = strings.ReplaceAll(s, "/", `\/`)
+	}
+	return s
+}
+
+// xdigit returns the character class of hexadecimal digits.
+func (b *regexpBuilder) xdigit() string {
+	if b.opts.Dialect == RegexpECMAScript {
+		return "[0-9A-Fa-f]"
+	}
+	return "[[:xdigit:]]"
+}
+
+// groupName mangles varname into a valid group name.
+func groupName(varname string) string {
+	var b strings.Builder
+	if c := varname[0]; '0' <= c && c <= '9' {
+		b.WriteByte('_')
+	}
+	for i := 0; i < len(varname); i++ {
+		switch c := varname[i]; c {
+		case '.', '%':
+			b.WriteByte('_')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (t *Template) regexpString(opts RegexpOptions) (string, map[string]string) {
+	b := regexpBuilder{
+		opts:   opts,
+		groups: make(map[string]string),
+	}
+	b.WriteByte('^')
+	for _, expr := range t.exprs {
+		expr.regexp(&b)
+	}
+	if b.opts.Dialect == RegexpPCRE {
+		// $ of PCRE also matches before a trailing newline
+		b.WriteString(`\z`)
+	} else {
+		b.WriteByte('$')
+	}
+	return b.String(), b.groups
+}
+
+// RegexpWithOptions converts the template to regexp as configured by opts.
+// It also returns the map from the names of the groups to the names of the
+// varspecs, which are the same as the names Match uses, such as "term:1".
+func (t *Template) RegexpWithOptions(opts RegexpOptions) (*regexp.Regexp, map[string]string) {
+	opts.Dialect = RegexpRE2
+	expr, groups := t.regexpString(opts)
+	return regexp.MustCompile(expr), groups
+}
+
+// RegexpString converts the template to regexp in opts.Dialect and returns
+// it as a string, along with the same map as RegexpWithOptions returns.
+func (t *Template) RegexpString(opts RegexpOptions) (string, map[string]string) {
+	return t.regexpString(opts)
+}
@@ -0,0 +1,289 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+)
+
+func ExampleTemplate_RegexpWithOptions() {
+	tmpl := MustNew("https://example.com/dictionary/{term:1}/{term}{?user.id}")
+	re, groups := tmpl.RegexpWithOptions(RegexpOptions{NamedGroups: true})
+
+	match := re.FindStringSubmatch("https://example.com/dictionary/c/cat?user.id=42")
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			fmt.Printf("%s (%s): %s\n", name, groups[name], match[i])
+		}
+	}
+
+	// Output:
+	// term (term:1): c
+	// term_2 (term): cat
+	// user_id (user.id): 42
+}
+
+func TestTemplate_RegexpWithOptions(t *testing.T) {
+	for _, c := range testTemplateCases {
+		tmpl, err := New(c.raw)
+		if err != nil {
+			t.Errorf("unexpected error on %q: %#v", c.raw, err)
+			continue
+		}
+		re, groups := tmpl.RegexpWithOptions(RegexpOptions{NamedGroups: true})
+		if !re.MatchString(c.expected) {
+			t.Errorf("on %q: regexp unexpectedly does not match: %q against %q", c.raw, re, c.expected)
+		}
+		for _, name := range re.SubexpNames()[1:] {
+			if _, ok := groups[name]; !ok {
+				t.Errorf("on %q: group %q is not mapped", c.raw, name)
+			}
+		}
+	}
+}
+
+func TestTemplate_RegexpWithOptions_Groups(t *testing.T) {
+	for _, c := range []struct {
+		raw      string
+		input    string
+		expected map[string]string
+	}{
+		{"{x,y}", "1024,768", map[string]string{"x": "1024", "y": "768"}},
+		{"{/list*}", "/red/green", map[string]string{"list": "red/green"}},
+		{"{;keys}", ";keys=a,b", map[string]string{"keys": "a,b"}},
+		{"{?x,empty}", "?x=1&empty=", map[string]string{"x": "1", "empty": ""}},
+		{"{1a}/{%20}", "x/y", map[string]string{"_1a": "x", "_20": "y"}},
+		{"{+path}/here", "/foo/bar/here", map[string]string{"path": "/foo/bar"}},
+	} {
+		tmpl := MustNew(c.raw)
+		re, _ := tmpl.RegexpWithOptions(RegexpOptions{NamedGroups: true})
+		match := re.FindStringSubmatch(c.input)
+		if match == nil {
+			t.Errorf("on %q: regexp unexpectedly does not match: %q against %q", c.raw, re, c.input)
+			continue
+		}
+		groups := make(map[string]string)
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				groups[name] = match[i]
+			}
+		}
+		for name, expected := range c.expected {
+			actual, ok := groups[name]
+			if !ok {
+				t.Errorf("on %q: group %q not found in %q", c.raw, name, re)
+				continue
+			}
+			if actual != expected {
+				t.Errorf("on %q: unexpected %s; got %q, expected %q", c.raw, name, actual, expected)
+			}
+		}
+	}
+}
+
+func ExampleTemplate_RegexpString() {
+	tmpl := MustNew("/users/{id}")
+	re, _ := tmpl.RegexpString(RegexpOptions{
+		Dialect:     RegexpECMAScript,
+		NamedGroups: true,
+	})
+	fmt.Println(re)
+
+	// Output:
+	// ^(?:\/users\/)(?:(?:(?<id>(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[0-9A-Fa-f][0-9A-Fa-f])*(?:,(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[0-9A-Fa-f][0-9A-Fa-f])*)*?))?)?$
+}
+
+func TestTemplate_RegexpString(t *testing.T) {
+	tmpl := MustNew("/{id}")
+	for _, c := range []struct {
+		dialect  RegexpDialect
+		expected string
+	}{
+		{RegexpRE2, `^(?:/)(?:(?:(?P<id>(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[[:xdigit:]][[:xdigit:]])*(?:,(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[[:xdigit:]][[:xdigit:]])*)*?))?)?$`},
+		{RegexpECMAScript, `^(?:\/)(?:(?:(?<id>(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[0-9A-Fa-f][0-9A-Fa-f])*(?:,(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[0-9A-Fa-f][0-9A-Fa-f])*)*?))?)?$`},
+		{RegexpPCRE, `^(?:/)(?:(?:(?<id>(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[[:xdigit:]][[:xdigit:]])*(?:,(?:[\x2d\x2e\x30-\x39\x41-\x5a\x5f\x61-\x7a\x7e]|%[[:xdigit:]][[:xdigit:]])*)*?))?)?\z`},
+	} {
+		re, _ := tmpl.RegexpString(RegexpOptions{Dialect: c.dialect, NamedGroups: true})
+		if re != c.expected {
+			t.Errorf("dialect %d: expected %q, but got %q", c.dialect, c.expected, re)
+		}
+	}
+}
+
+// regexpEngineCase is a regexp and the inputs to run it against.
+type regexpEngineCase struct {
+	Re     string   `json:"re"`
+	Inputs []string `json:"inputs"`
+}
+
+// runRegexpEngine runs the script of an external regexp engine with
+// cases in JSON as the standard input. The script prints, for each input
+// of each case, the groups in an object if the regexp matches, or null.
+func runRegexpEngine(t *testing.T, name string, args []string, cases []regexpEngineCase) [][]map[string]string {
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s is not installed", name)
+	}
+	in, err := json.Marshal(cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	var results [][]map[string]string
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("%s: %v: %s", name, err, out)
+	}
+	return results
+}
+
+// sampleMutations returns s and every fifth of the other mutations of s,
+// for tests that run over all of testTemplateCases.
+func sampleMutations(s string) []string {
+	var ret []string
+	for i, m := range mutations(s) {
+		if i%5 == 0 {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+func TestTemplate_RegexpString_Engines(t *testing.T) {
+	templates := []struct {
+		raw      string
+		expected string
+	}{
+		{"/dictionary/{term:1}/{term}", "/dictionary/c/cat"},
+		{"{?q:2,lang}", "?q=%E3%81%82a&lang=ja"},
+		{"/日本/{x}", "/日本/x"},
+		{"{1a}/{%20}{?user.id}", "x/y?user.id=1"},
+	}
+	for _, c := range testTemplateCases {
+		templates = append(templates, struct {
+			raw      string
+			expected string
+		}{c.raw, c.expected})
+	}
+
+	for _, engine := range []struct {
+		dialect RegexpDialect
+		name    string
+		args    []string
+	}{
+		{RegexpECMAScript, "node", []string{"-e", `
+const cases = JSON.parse(require("fs").readFileSync(0, "utf8"));
+console.log(JSON.stringify(cases.map(c => {
+	const re = new RegExp(c.re, "u");
+	return c.inputs.map(s => {
+		const m = re.exec(s);
+		return m && Object.fromEntries(Object.entries(m.groups || {}).map(([k, v]) => [k, v || ""]));
+	});
+})));`}},
+		// PCRE implements the syntax of Perl.
+		{RegexpPCRE, "perl", []string{"-MJSON::PP", "-e", `
+binmode STDOUT, ":utf8";
+my $cases = JSON::PP->new->utf8->decode(join "", <STDIN>);
+print JSON::PP->new->encode([map {
+	my $re = qr/$_->{re}/;
+	[map { $_ =~ $re ? {map { ($_, $+{$_} // "") } keys %+} : undef } @{$_->{inputs}}];
+} @$cases]);`}},
+	} {
+		engine := engine
+		t.Run(engine.name, func(t *testing.T) {
+			var cases []regexpEngineCase
+			var goRegexps []*regexp.Regexp
+			var expansions []string
+			for _, c := range templates {
+				tmpl := MustNew(c.raw)
+				for _, named := range []bool{false, true} {
+					opts := RegexpOptions{Dialect: engine.dialect, NamedGroups: named}
+					re, _ := tmpl.RegexpString(opts)
+					cases = append(cases, regexpEngineCase{Re: re, Inputs: sampleMutations(c.expected)})
+					goRe, _ := tmpl.RegexpWithOptions(opts)
+					goRegexps = append(goRegexps, goRe)
+					expansions = append(expansions, c.expected)
+				}
+			}
+
+			results := runRegexpEngine(t, engine.name, engine.args, cases)
+			if len(results) != len(cases) {
+				t.Fatalf("expected %d results, but got %d", len(cases), len(results))
+			}
+			for i, c := range cases {
+				for j, s := range c.Inputs {
+					expected := goRegexps[i].FindStringSubmatch(s)
+					actual := results[i][j]
+					if (expected != nil) != (actual != nil) {
+						t.Errorf("%q returns %t against %q, but the regexp package returns %t", c.Re, actual != nil, s, expected != nil)
+						continue
+					}
+					// Backtracking engines may capture differently from the
+					// regexp package if the regexp matches s in more than one
+					// way, as mutations of expansions often do.
+					if expected == nil || s != expansions[i] {
+						continue
+					}
+					for k, name := range goRegexps[i].SubexpNames() {
+						if name != "" && actual[name] != expected[k] {
+							t.Errorf("%q captures %q in %s against %q, but the regexp package captures %q", c.Re, actual[name], name, s, expected[k])
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// mutations returns s and strings made by slightly modifying s.
+func mutations(s string) []string {
+	ret := []string{s, "", s + "x", "x" + s}
+	for i := 0; i < len(s); i++ {
+		ret = append(ret, s[:i], s[:i]+s[i+1:])
+		for _, ins := range []string{"a", "/", ",", "=", ";", "&", "?", "%41", "%4", "é"} {
+			ret = append(ret, s[:i]+ins+s[i:], s[:i]+ins+s[i+1:])
+		}
+	}
+	return ret
+}
+
+func TestTemplate_RegexpAgreesWithMatch(t *testing.T) {
+	cases := []struct {
+		raw    string
+		inputs []string
+	}{
+		{"/dictionary/{term:1}/{term}", mutations("/dictionary/c/cat")},
+		{"{?q:2,lang}", mutations("?q=%E3%81%82a&lang=ja")},
+		{"{+path:6}/here", mutations("/foo/b/here")},
+		{"{;list:3}", mutations(";list=abc,d%2F")},
+	}
+	for _, c := range testTemplateCases {
+		cases = append(cases, struct {
+			raw    string
+			inputs []string
+		}{c.raw, sampleMutations(c.expected)})
+	}
+	for _, c := range cases {
+		tmpl := MustNew(c.raw)
+		re := tmpl.Regexp()
+		for _, s := range c.inputs {
+			if expected, got := tmpl.Match(s) != nil, re.MatchString(s); got != expected {
+				t.Errorf("on %q: regexp %q returns %t against %q, but Match returns %t", c.raw, re, got, s, expected)
+			}
+		}
+	}
+}
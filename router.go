@@ -0,0 +1,253 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Router matches URIs against many templates at once.
+//
+// Templates are compiled into a single program, and Lookup runs it over
+// the URI in a single pass. When more than one template matches, the most
+// specific one as ordered by Compare takes precedence, then the one
+// registered first.
+//
+// Templates can also be registered under names to build URLs by name.
+//
+// The zero value for Router is an empty Router ready to use.
+type Router struct {
+	// VerifyURLs makes URL check that the URL it builds matches the
+	// template again. It is intended for debugging.
+	VerifyURLs bool
+
+	mu     sync.Mutex
+	routes []*Template
+	names  map[string]int
+	prog   *prog
+	rank   []int // rank[id] is the precedence of routes[id]; lower is preferred
+}
+
+// Add registers tmpl and returns its ID. IDs are assigned sequentially
+// from 0 in order of registration.
+func (r *Router) Add(tmpl *Template) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.add(tmpl)
+}
+
+func (r *Router) add(tmpl *Template) int {
+	r.routes = append(r.routes, tmpl)
+	r.prog = nil
+	return len(r.routes) - 1
+}
+
+// AddNamed registers tmpl under name and returns its ID.
+// AddNamed panics if name is already registered, and registers nothing
+// in that case.
+func (r *Router) AddNamed(name string, tmpl *Template) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkName(name)
+	id := r.add(tmpl)
+	r.names[name] = id
+	return id
+}
+
+func (r *Router) setName(id int, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkName(name)
+	r.names[name] = id
+}
+
+// checkName panics if name is already registered.
+func (r *Router) checkName(name string) {
+	if _, ok := r.names[name]; ok {
+		panic("uritemplate: multiple registrations for " + name)
+	}
+	if r.names == nil {
+		r.names = make(map[string]int)
+	}
+}
+
+// Named returns the ID of the template registered under name.
+func (r *Router) Named(name string) (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.names[name]
+	return id, ok
+}
+
+// RouteError describes a failure of Router.URL.
+type RouteError struct {
+	Name     string   // the name of the route
+	Varnames []string // the missing variables for ErrMissingVariable
+	Err      error
+}
+
+func (e *RouteError) Error() string {
+	if len(e.Varnames) > 0 {
+		return fmt.Sprintf("uritemplate: route %s: %s: %s", e.Name, e.Err, strings.Join(e.Varnames, ", "))
+	}
+	return fmt.Sprintf("uritemplate: route %s: %s", e.Name, e.Err)
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// URL expands the template registered under name with vars.
+//
+// Variables in expressions other than {?...} and {&...} are required, and
+// URL reports those not defined in vars as ErrMissingVariable.
+// If VerifyURLs is set, URL reports ErrURLMismatch if the template does
+// not match the URL it builds.
+func (r *Router) URL(name string, vars Values) (string, error) {
+	return r.url(name, vars, r.VerifyURLs)
+}
+
+func (r *Router) url(name string, vars Values, verify bool) (string, error) {
+	id, ok := r.Named(name)
+	if !ok {
+		return "", &RouteError{Name: name, Err: ErrUnknownRoute}
+	}
+	tmpl := r.Template(id)
+
+	var missing []string
+	for i := range tmpl.exprs {
+		expr, ok := tmpl.exprs[i].(*expression)
+		if !ok || expr.op == OpQuestion || expr.op == OpAmpersand {
+			continue
+		}
+		for _, spec := range expr.vars {
+			if !vars.Get(spec.name).Valid() {
+				missing = appendUnique(missing, spec.name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return "", &RouteError{Name: name, Varnames: missing, Err: ErrMissingVariable}
+	}
+
+	uri, err := tmpl.Expand(vars)
+	if err != nil {
+		return "", err
+	}
+	if verify && tmpl.Match(uri) == nil {
+		return "", &RouteError{Name: name, Err: ErrURLMismatch}
+	}
+	return uri, nil
+}
+
+func appendUnique(a []string, s string) []string {
+	for i := range a {
+		if a[i] == s {
+			return a
+		}
+	}
+	return append(a, s)
+}
+
+// Template returns the template registered with id.
+func (r *Router) Template(id int) *Template {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.routes[id]
+}
+
+// Len returns the number of templates registered.
+func (r *Router) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.routes)
+}
+
+// Lookup returns the ID of the template that matches uri with the highest
+// precedence and the variables captured by it as Match does. It returns -1
+// and nil if no template matches.
+func (r *Router) Lookup(uri string) (int, Values) {
+	matches := r.lookupAll(uri)
+	if len(matches) == 0 {
+		return -1, nil
+	}
+	return matches[0].id, matches[0].vars
+}
+
+// routeMatch is a template that matches a URI.
+type routeMatch struct {
+	id   int
+	vars Values
+}
+
+// lookupAll returns all the templates that match uri in order of
+// precedence.
+func (r *Router) lookupAll(uri string) []routeMatch {
+	prog, rank := r.compile()
+	if prog == nil {
+		return nil
+	}
+
+	m := newMatcher(prog, uri)
+	m.all = true
+	m.ends = make(map[uint32]map[string][]int)
+	if !m.match() {
+		return nil
+	}
+
+	matches := make([]routeMatch, 0, len(m.ends))
+	for id, cap := range m.ends {
+		matches = append(matches, routeMatch{
+			id:   int(id),
+			vars: captureValues(uri, cap, nil),
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return rank[matches[i].id] < rank[matches[j].id]
+	})
+	return matches
+}
+
+func (r *Router) compile() (*prog, []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.prog != nil || len(r.routes) == 0 {
+		return r.prog, r.rank
+	}
+
+	c := compiler{}
+	c.init()
+	n := len(r.routes)
+	for i := 0; i < n-1; i++ {
+		c.op(opSplit)
+	}
+	c.op(opJmp)
+	for i, tmpl := range r.routes {
+		c.prog.op[i].i = uint32(len(c.prog.op))
+		c.compile(tmpl)
+		c.prog.op[len(c.prog.op)-1].i = uint32(i) // opEnd
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return Compare(r.routes[order[i]], r.routes[order[j]]) < 0
+	})
+	rank := make([]int, n)
+	for i, id := range order {
+		rank[id] = i
+	}
+
+	r.prog = c.prog
+	r.rank = rank
+	return r.prog, r.rank
+}
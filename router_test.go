@@ -0,0 +1,133 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleRouter() {
+	var r Router
+	r.Add(MustNew("/users/{id}"))
+	me := r.Add(MustNew("/users/me"))
+	r.Add(MustNew("/users/{id}/posts{/post}"))
+
+	id, match := r.Lookup("/users/me")
+	fmt.Println(id == me, len(match))
+
+	id, match = r.Lookup("/users/yosida95/posts/1")
+	fmt.Println(r.Template(id).Raw(), match.Get("id").String(), match.Get("post").String())
+
+	// Output:
+	// true 0
+	// /users/{id}/posts{/post} yosida95 1
+}
+
+func TestRouter_Lookup(t *testing.T) {
+	var r Router
+	if id, match := r.Lookup("/"); id != -1 || match != nil {
+		t.Errorf("empty router must not match")
+	}
+
+	tmpls := []string{
+		"/{a}/{b}",
+		"/{a}{/b,c}",
+		"/x/{b}",
+		"/x/y",
+		"{/path*}",
+		"/x/{b}",
+		"/{a}-bbbbbb",
+		"/y{b}",
+	}
+	for _, raw := range tmpls {
+		r.Add(MustNew(raw))
+	}
+
+	for _, c := range []struct {
+		uri      string
+		expected int
+	}{
+		{"/x/y", 3},
+		{"/x/z", 2},
+		{"/w/z", 0},
+		{"/w/z/y", 1},
+		{"/w", 1},
+		{"/w/z/y/v", 4},
+		{"/y-bbbbbb", 7}, // as Compare orders, not by the number of literals
+		{"x", -1},
+	} {
+		id, match := r.Lookup(c.uri)
+		if id != c.expected {
+			t.Errorf("on %q: expected %d, got %d", c.uri, c.expected, id)
+			continue
+		}
+		if id < 0 {
+			continue
+		}
+		expected := r.Template(id).Match(c.uri)
+		if fmt.Sprint(match) != fmt.Sprint(expected) {
+			t.Errorf("on %q: expected %v, got %v", c.uri, expected, match)
+		}
+	}
+}
+
+func ExampleRouter_URL() {
+	var r Router
+	r.AddNamed("user.show", MustNew("/users/{id}{?fields}"))
+
+	uri, err := r.URL("user.show", Values{"id": String("yosida95")})
+	fmt.Println(uri, err)
+
+	_, err = r.URL("user.show", Values{"fields": String("name")})
+	fmt.Println(err)
+
+	// Output:
+	// /users/yosida95 <nil>
+	// uritemplate: route user.show: missing variable: id
+}
+
+func TestRouter_URL(t *testing.T) {
+	r := Router{VerifyURLs: true}
+	r.AddNamed("a", MustNew("/{x}{y}"))
+	r.AddNamed("b", MustNew("/{x:1}/{x}"))
+
+	if _, err := r.URL("c", nil); !errors.Is(err, ErrUnknownRoute) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if uri, err := r.URL("a", Values{"x": String("1"), "y": String("2")}); err != nil || uri != "/12" {
+		t.Errorf("unexpected result: %q, %v", uri, err)
+	}
+	if _, err := r.URL("b", Values{"x": String("xyz")}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var rerr *RouteError
+	if _, err := r.URL("a", Values{"y": List()}); !errors.As(err, &rerr) || rerr.Name != "a" ||
+		fmt.Sprint(rerr.Varnames) != "[x y]" || !errors.Is(err, ErrMissingVariable) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRouter_AddNamed_Duplicated(t *testing.T) {
+	var r Router
+	defer func() {
+		if recover() == nil {
+			t.Errorf("must panic")
+		}
+		if n := r.Len(); n != 1 {
+			t.Errorf("expected 1 route, but got %d", n)
+		}
+		if id, _ := r.Lookup("/b"); id >= 0 {
+			t.Errorf("/b must not be registered, but got %d", id)
+		}
+	}()
+
+	r.AddNamed("a", MustNew("/a"))
+	r.AddNamed("a", MustNew("/b"))
+}
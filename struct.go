@@ -0,0 +1,566 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type structField struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+type structInfo struct {
+	fields []structField
+	hints  map[string]ValueType // used by MatchInto
+}
+
+// structInfoCache caches *structInfo by reflect.Type.
+var structInfoCache sync.Map
+
+func cachedStructInfo(t reflect.Type) *structInfo {
+	if info, ok := structInfoCache.Load(t); ok {
+		return info.(*structInfo)
+	}
+
+	info := &structInfo{
+		hints: make(map[string]ValueType),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		tag := f.Tag.Get("uri")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := splitTag(tag)
+		if name == "" {
+			name = f.Name
+		}
+		info.fields = append(info.fields, structField{
+			name:      name,
+			index:     f.Index,
+			omitempty: opts == "omitempty",
+		})
+		if hint, ok := valueTypeHint(f.Type); ok {
+			info.hints[name] = hint
+		}
+	}
+
+	actual, _ := structInfoCache.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
+
+// valueTypeHint returns the value type that MatchInto requests for
+// a field of type t.
+func valueTypeHint(t reflect.Type) (ValueType, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return 0, false
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct:
+		return ValueTypeKV, true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return 0, false
+		}
+		return ValueTypeList, true
+	case reflect.Array:
+		return ValueTypeList, true
+	}
+	return 0, false
+}
+
+func splitTag(tag string) (string, string) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// ValuesFrom returns Values built from the exported fields of the struct v,
+// or of the struct that v points to.
+//
+// The variable name of each field is the field name unless the field has
+// a "uri" tag. The tag "-" omits the field, and the "omitempty" option,
+// as in `uri:"name,omitempty"`, leaves the variable undefined if the field
+// has the zero value. Nil pointers and interfaces are undefined as well.
+//
+// Values that implement encoding.TextMarshaler, such as time.Time, are
+// encoded with MarshalText. Strings, booleans, integers and floating point
+// numbers are String. Slices and arrays are List, except for []byte which
+// is String. Maps are KV ordered by keys, and nested structs are KV ordered
+// by fields.
+func ValuesFrom(v interface{}) (Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("uritemplate: ValuesFrom of non-struct type %T", v)
+	}
+
+	info := cachedStructInfo(rv.Type())
+	vars := make(Values, len(info.fields))
+	for _, f := range info.fields {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		value, ok, err := encodeValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("uritemplate: field %s: %w", f.name, err)
+		}
+		if ok {
+			vars.Set(f.name, value)
+		}
+	}
+	return vars, nil
+}
+
+// ExpandStruct expands the template using the variables built from v by
+// ValuesFrom.
+func (t *Template) ExpandStruct(v interface{}) (string, error) {
+	vars, err := ValuesFrom(v)
+	if err != nil {
+		return "", err
+	}
+	return t.Expand(vars)
+}
+
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		if v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+func encodeValue(v reflect.Value) (Value, bool, error) {
+	v, ok := indirect(v)
+	if !ok {
+		return Value{}, false, nil
+	}
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return Value{}, false, nil
+	}
+	if s, ok, err := encodeScalar(v); ok || err != nil {
+		return String(s), ok, err
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, ok := indirect(v.Index(i))
+			if !ok {
+				continue
+			}
+			s, ok, err := encodeScalar(elem)
+			if err != nil {
+				return Value{}, false, err
+			} else if !ok {
+				return Value{}, false, fmt.Errorf("unsupported list element type %s", elem.Type())
+			}
+			list = append(list, s)
+		}
+		return List(list...), true, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return Value{}, false, nil
+		}
+		kv := make([]string, 0, 2*v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := encodeKVElem(iter.Key())
+			if err != nil {
+				return Value{}, false, err
+			}
+			elem, ok := indirect(iter.Value())
+			if !ok {
+				continue
+			}
+			val, err := encodeKVElem(elem)
+			if err != nil {
+				return Value{}, false, err
+			}
+			kv = append(kv, key, val)
+		}
+		sort.Sort(kvByKey(kv))
+		return KV(kv...), true, nil
+	case reflect.Struct:
+		info := cachedStructInfo(v.Type())
+		kv := make([]string, 0, 2*len(info.fields))
+		for _, f := range info.fields {
+			fv := v.FieldByIndex(f.index)
+			if f.omitempty && fv.IsZero() {
+				continue
+			}
+			elem, ok := indirect(fv)
+			if !ok {
+				continue
+			}
+			val, err := encodeKVElem(elem)
+			if err != nil {
+				return Value{}, false, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			kv = append(kv, f.name, val)
+		}
+		return KV(kv...), true, nil
+	}
+	return Value{}, false, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func encodeKVElem(v reflect.Value) (string, error) {
+	s, ok, err := encodeScalar(v)
+	if err == nil && !ok {
+		err = fmt.Errorf("unsupported associative array element type %s", v.Type())
+	}
+	return s, err
+}
+
+func encodeScalar(v reflect.Value) (string, bool, error) {
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err == nil, err
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		text, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err == nil, err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), true, nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), true, nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// kvByKey sorts an associative list by keys.
+type kvByKey []string
+
+func (kv kvByKey) Len() int {
+	return len(kv) / 2
+}
+
+func (kv kvByKey) Less(i, j int) bool {
+	return kv[2*i] < kv[2*j]
+}
+
+func (kv kvByKey) Swap(i, j int) {
+	kv[2*i], kv[2*j] = kv[2*j], kv[2*i]
+	kv[2*i+1], kv[2*j+1] = kv[2*j+1], kv[2*i+1]
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// FieldError describes a matched variable that cannot be stored in a
+// struct field.
+type FieldError struct {
+	Varname string
+	Field   string
+	Err     error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("uritemplate: cannot decode %s into field %s: %v", e.Varname, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors is a list of FieldError reported by MatchInto.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// MatchInto matches the template against expansion and stores the matched
+// variables in the struct that dst points to. Fields are associated with
+// variables in the same way as ValuesFrom. A variable captured only with
+// a prefix modifier, such as term:1, is stored in the field for term.
+// Variables for map and struct fields are matched as associative arrays,
+// and those for slice and array fields as lists; see MatchWithHints.
+//
+// MatchInto reports false if the template does not match expansion, or if
+// a prefix capture is not consistent with the other captures of the same
+// variable. Variables that cannot be converted into the types of fields
+// are reported as FieldErrors after the other fields are stored.
+func (t *Template) MatchInto(expansion string, dst interface{}) (bool, error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return false, fmt.Errorf("uritemplate: MatchInto of non-pointer to struct %T", dst)
+	}
+	rv = rv.Elem()
+
+	info := cachedStructInfo(rv.Type())
+	match := t.MatchWithHints(expansion, info.hints)
+	if match == nil {
+		return false, nil
+	}
+
+	// captures holds the captures of each variable, whose maxlen is 0 for
+	// the full capture.
+	captures := make(map[string][]prefixCapture)
+	for name, v := range match {
+		varname, maxlen := name, 0
+		if colon := strings.IndexByte(name, ':'); colon >= 0 {
+			varname = name[:colon]
+			maxlen, _ = strconv.Atoi(name[colon+1:])
+		}
+		if maxlen > 0 && v.T == ValueTypeList {
+			// The same varspec with a prefix modifier is captured more
+			// than once.
+			for _, s := range v.V {
+				captures[varname] = append(captures[varname], prefixCapture{maxlen: maxlen, value: String(s)})
+			}
+			continue
+		}
+		captures[varname] = append(captures[varname], prefixCapture{maxlen: maxlen, value: v})
+	}
+	prefixes := make(map[string]int)
+	for varname, cs := range captures {
+		if len(cs) == 1 && cs[0].maxlen == 0 {
+			continue
+		}
+		for i := range cs {
+			for j := range cs {
+				if i != j && !cs[i].consistentWith(cs[j]) {
+					return false, nil
+				}
+			}
+			if cs[i].maxlen > prefixes[varname] {
+				prefixes[varname] = cs[i].maxlen
+			}
+		}
+	}
+
+	var errs FieldErrors
+	for _, f := range info.fields {
+		name := f.name
+		v, ok := match[name]
+		if !ok {
+			maxlen, ok := prefixes[name]
+			if !ok {
+				continue
+			}
+			name = name + ":" + strconv.Itoa(maxlen)
+			v = match[name]
+			if v.T == ValueTypeList {
+				v = String(v.V[0])
+			}
+		}
+		if err := decodeValue(rv.FieldByIndex(f.index), v); err != nil {
+			errs = append(errs, &FieldError{
+				Varname: name,
+				Field:   f.name,
+				Err:     err,
+			})
+		}
+	}
+	if errs != nil {
+		return true, errs
+	}
+	return true, nil
+}
+
+// prefixCapture is a capture of a variable with a prefix modifier of
+// maxlen, or without one if maxlen is 0.
+type prefixCapture struct {
+	maxlen int
+	value  Value
+}
+
+// consistentWith reports whether c can be captured along with other from
+// the same string value, that is, the shorter of them is the prefix of
+// the other in code points.
+func (c prefixCapture) consistentWith(other prefixCapture) bool {
+	if c.value.T != ValueTypeString || other.value.T != ValueTypeString {
+		return false
+	}
+	if other.maxlen != 0 && (c.maxlen == 0 || other.maxlen < c.maxlen) {
+		return true // checked the other way around
+	}
+	return prefix(other.value.V[0], c.maxlen) == c.value.V[0]
+}
+
+func decodeValue(v reflect.Value, value Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	var elems []string
+	switch value.T {
+	case ValueTypeString:
+		elems = value.V[:1]
+	case ValueTypeList:
+		elems = value.V
+	case ValueTypeKV:
+		return decodeKV(v, value.V)
+	default:
+		return fmt.Errorf("unsupported value type %s", value.T)
+	}
+
+	if !v.Addr().Type().Implements(textUnmarshalerType) {
+		switch v.Kind() {
+		case reflect.Slice:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				break
+			}
+			s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+			for i := range elems {
+				if err := decodeScalar(s.Index(i), elems[i]); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		case reflect.Array:
+			if len(elems) > v.Len() {
+				return fmt.Errorf("too many values for %s", v.Type())
+			}
+			for i := range elems {
+				if err := decodeScalar(v.Index(i), elems[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	if len(elems) != 1 {
+		return fmt.Errorf("cannot decode %s into %s", value.T, v.Type())
+	}
+	return decodeScalar(v, elems[0])
+}
+
+func decodeKV(v reflect.Value, kv []string) error {
+	switch v.Kind() {
+	case reflect.Map:
+		m := reflect.MakeMapWithSize(v.Type(), len(kv)/2)
+		for i := 0; i < len(kv); i += 2 {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := decodeScalar(key, kv[i]); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeScalar(elem, kv[i+1]); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		info := cachedStructInfo(v.Type())
+		for i := 0; i < len(kv); i += 2 {
+			for _, f := range info.fields {
+				if f.name != kv[i] {
+					continue
+				}
+				if err := decodeScalar(v.FieldByIndex(f.index), kv[i+1]); err != nil {
+					return fmt.Errorf("field %s: %w", f.name, err)
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot decode %s into %s", ValueType(ValueTypeKV), v.Type())
+}
+
+func decodeScalar(v reflect.Value, s string) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleTemplate_ExpandStruct() {
+	type params struct {
+		User  string   `uri:"user"`
+		Tags  []string `uri:"tags"`
+		Page  int      `uri:"page,omitempty"`
+		Debug bool     `uri:"-"`
+	}
+
+	tmpl := MustNew("https://example.com/users/{user}/posts{?tags*,page}")
+	ret, err := tmpl.ExpandStruct(params{User: "yosida95", Tags: []string{"go", "uri"}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(ret)
+
+	// Output:
+	// https://example.com/users/yosida95/posts?tags=go&tags=uri
+}
+
+type testStructNested struct {
+	B string `uri:"b"`
+	A int    `uri:"a"`
+	C *int   `uri:"c"`
+}
+
+type testStruct struct {
+	Str      string            `uri:"str"`
+	Int      int64             `uri:"int"`
+	Uint     uint8             `uri:"uint"`
+	Float    float64           `uri:"float"`
+	Bool     bool              `uri:"bool"`
+	Time     time.Time         `uri:"time"`
+	PtrTime  *time.Time        `uri:"ptr_time"`
+	Bytes    []byte            `uri:"bytes"`
+	List     []int             `uri:"list"`
+	Array    [2]bool           `uri:"array"`
+	Map      map[string]uint   `uri:"map"`
+	Nested   testStructNested  `uri:"nested"`
+	Nil      *testStructNested `uri:"nil"`
+	Empty    string            `uri:"empty,omitempty"`
+	Untagged string
+	Skipped  string `uri:"-"`
+	private  string
+}
+
+func TestValuesFrom(t *testing.T) {
+	v := testStruct{
+		Str:      "str",
+		Int:      -1,
+		Uint:     2,
+		Float:    0.5,
+		Bool:     true,
+		Time:     time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		Bytes:    []byte("bytes"),
+		List:     []int{1, 2},
+		Array:    [2]bool{true, false},
+		Map:      map[string]uint{"z": 1, "y": 2},
+		Nested:   testStructNested{B: "b", A: 1},
+		Untagged: "untagged",
+		Skipped:  "skipped",
+		private:  "private",
+	}
+	expected := Values{
+		"str":      String("str"),
+		"int":      String("-1"),
+		"uint":     String("2"),
+		"float":    String("0.5"),
+		"bool":     String("true"),
+		"time":     String("2016-01-02T03:04:05Z"),
+		"bytes":    String("bytes"),
+		"list":     List("1", "2"),
+		"array":    List("true", "false"),
+		"map":      KV("y", "2", "z", "1"),
+		"nested":   KV("b", "b", "a", "1"),
+		"Untagged": String("untagged"),
+	}
+
+	for _, arg := range []interface{}{v, &v} {
+		vars, err := ValuesFrom(arg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(vars) != len(expected) {
+			t.Errorf("expected %d variables, got %#v", len(expected), vars)
+		}
+		for name, e := range expected {
+			if a := vars.Get(name); a.T != e.T || fmt.Sprint(a.V) != fmt.Sprint(e.V) {
+				t.Errorf("%s: expected %#v, got %#v", name, e, a)
+			}
+		}
+	}
+}
+
+func TestValuesFrom_Error(t *testing.T) {
+	for _, v := range []interface{}{
+		"not a struct",
+		struct{ C chan int }{make(chan int)},
+		struct{ L [][]string }{[][]string{{"a"}}},
+		struct{ M map[string][]string }{map[string][]string{"a": {"b"}}},
+	} {
+		if _, err := ValuesFrom(v); err == nil {
+			t.Errorf("expected error on %#v", v)
+		}
+	}
+}
+
+func ExampleTemplate_MatchInto() {
+	var entry struct {
+		Initial string `uri:"term"`
+		Page    int    `uri:"page"`
+	}
+
+	tmpl := MustNew("https://example.com/dictionary/{term:1}{?page}")
+	ok, err := tmpl.MatchInto("https://example.com/dictionary/c?page=2", &entry)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(ok, entry.Initial, entry.Page)
+
+	// Output:
+	// true c 2
+}
+
+func TestTemplate_MatchInto(t *testing.T) {
+	type dst struct {
+		Term  string     `uri:"term"`
+		ID    *uint16    `uri:"id"`
+		Flag  bool       `uri:"flag"`
+		Tags  []string   `uri:"tags"`
+		Nums  [3]float64 `uri:"nums"`
+		Since time.Time  `uri:"since"`
+	}
+
+	tmpl := MustNew("/{term:1}/{term}/{id}{?flag,tags*,nums,since}")
+	var v dst
+	ok, err := tmpl.MatchInto("/c/cat/42?flag=true&tags=a&tags=b&nums=1.5,2&since=2016-01-02T03%3A04%3A05Z", &v)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v, %v", ok, err)
+	}
+	if v.Term != "cat" || v.ID == nil || *v.ID != 42 || !v.Flag ||
+		fmt.Sprint(v.Tags) != "[a b]" || v.Nums != [3]float64{1.5, 2, 0} ||
+		!v.Since.Equal(time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected result: %#v", v)
+	}
+
+	// inconsistent prefix
+	if ok, err := tmpl.MatchInto("/d/cat/42", &v); ok || err != nil {
+		t.Errorf("unexpected result: %v, %v", ok, err)
+	}
+
+	for _, c := range []struct {
+		raw       string
+		expansion string
+		ok        bool
+		expected  string
+	}{
+		{"/{t:1}/{t:3}", "/x/xyz", true, "xyz"},
+		{"/{t:1}/{t:3}", "/a/xyz", false, ""},
+		{"/{t:3}/{t:1}", "/xyz/a", false, ""},
+		{"/{t:3}/{t:1}/{t:5}", "/xy/x/xy", true, "xy"},
+		{"/{t:3}/{t:5}", "/xy/xyz", false, ""},
+		{"/{t:1}/{t:1}", "/a/b", false, ""},
+		{"/{t:1}/{t:1}", "/a/a", true, "a"},
+		{"/{t:1}/{t:2}", "/%E3%81%82/%E3%81%82a", true, "あa"},
+	} {
+		var v struct {
+			T string `uri:"t"`
+		}
+		ok, err := MustNew(c.raw).MatchInto(c.expansion, &v)
+		if ok != c.ok || err != nil {
+			t.Errorf("on %q against %q: unexpected result: %v, %v", c.raw, c.expansion, ok, err)
+			continue
+		}
+		if v.T != c.expected {
+			t.Errorf("on %q against %q: expected %q, but got %q", c.raw, c.expansion, c.expected, v.T)
+		}
+	}
+
+	ok, err = tmpl.MatchInto("/c/cat/-1?flag=yes&tags=a", &v)
+	if !ok {
+		t.Fatalf("must match")
+	}
+	errs, _ := err.(FieldErrors)
+	if len(errs) != 2 || errs[0].Varname != "id" || errs[1].Varname != "flag" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTemplate_MatchInto_KV(t *testing.T) {
+	var v struct {
+		Filter map[string]int `uri:"filter"`
+		Page   struct {
+			Size   int `uri:"size"`
+			Cursor string
+		} `uri:"page"`
+	}
+
+	tmpl := MustNew("/items{?filter*}{&page}")
+	ok, err := tmpl.MatchInto("/items?min=1&max=10&page=size,20,Cursor,abc", &v)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v, %v", ok, err)
+	}
+	if len(v.Filter) != 2 || v.Filter["min"] != 1 || v.Filter["max"] != 10 {
+		t.Errorf("unexpected filter: %#v", v.Filter)
+	}
+	if v.Page.Size != 20 || v.Page.Cursor != "abc" {
+		t.Errorf("unexpected page: %#v", v.Page)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Subsumes reports whether every URI matched by specific is also matched
+// by general.
+//
+// Subsumes runs the program of specific in lockstep with the set of the
+// states the program of general may be in, so it looks for a path of
+// specific reaching the end while none of general does.
+func Subsumes(general, specific *Template) bool {
+	cg := closures{
+		prog:  general.compile(nil, false),
+		cache: make(map[uint32][]closureEnd),
+		caps:  make(map[string]map[string]capCount),
+	}
+	cs := closures{
+		prog:  specific.compile(nil, false),
+		cache: make(map[uint32][]closureEnd),
+		caps:  make(map[string]map[string]capCount),
+	}
+
+	// rune classes consist of ASCII characters, so the other runes that
+	// specific consumes are in opRune.
+	var runes []rune
+	for r := rune(0); r < utf8.RuneSelf; r++ {
+		runes = append(runes, r)
+	}
+	for _, op := range cs.prog.op {
+		if op.code == opRune && op.r >= utf8.RuneSelf {
+			runes = append(runes, op.r)
+		}
+	}
+
+	type state struct {
+		pc  uint32
+		set string // the pcs of general
+	}
+	sets := make(map[string][]uint32)
+	setKey := func(pcs []uint32) string {
+		sort.Slice(pcs, func(i, j int) bool { return pcs[i] < pcs[j] })
+		var b strings.Builder
+		for i, pc := range pcs {
+			if i > 0 && pcs[i-1] == pc {
+				continue
+			}
+			b.WriteString(strconv.FormatUint(uint64(pc), 10))
+			b.WriteByte(',')
+		}
+		key := b.String()
+		if _, ok := sets[key]; !ok {
+			sets[key] = pcs
+		}
+		return key
+	}
+	start := state{0, setKey([]uint32{0})}
+
+	seen := map[state]bool{start: true}
+	queue := []state{start}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		var ends []uint32
+		var accepted bool
+		for _, pc := range sets[s.set] {
+			for _, e := range cg.get(pc) {
+				if cg.prog.op[e.pc].code == opEnd {
+					accepted = true
+				} else {
+					ends = append(ends, e.pc)
+				}
+			}
+		}
+
+		for _, e := range cs.get(s.pc) {
+			op := &cs.prog.op[e.pc]
+			if op.code == opEnd {
+				if !accepted {
+					return false
+				}
+				continue
+			}
+			for _, r := range runes {
+				if !op.accepts(r) {
+					continue
+				}
+				var pcs []uint32
+				for _, pc := range ends {
+					if cg.prog.op[pc].accepts(r) {
+						pcs = append(pcs, pc+1)
+					}
+				}
+				next := state{e.pc + 1, setKey(pcs)}
+				if !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Ranks of the parts of templates in the specificity order.
+const (
+	rankLiteral = iota
+	rankPrefix
+	rankFull
+	rankExplode
+)
+
+// specificity returns the ranks of the literal runes and varspecs of t in
+// order. Each rank of a varspec is doubled, plus one if the expression
+// allows reserved characters.
+func (t *Template) specificity() []int {
+	var ranks []int
+	for _, expr := range t.exprs {
+		switch expr := expr.(type) {
+		case literals:
+			for range string(expr) {
+				ranks = append(ranks, rankLiteral)
+			}
+		case *expression:
+			reserved := 0
+			if expr.allow&runeClassR == runeClassR {
+				reserved = 1
+			}
+			for _, spec := range expr.vars {
+				rank := rankFull
+				switch {
+				case spec.explode:
+					rank = rankExplode
+				case spec.maxlen > 0:
+					rank = rankPrefix
+				}
+				ranks = append(ranks, rank*2+reserved)
+			}
+		}
+	}
+	return ranks
+}
+
+// Compare orders t1 and t2 by specificity. It returns a negative number if
+// t1 is more specific than t2, a positive number if less, and 0 only if
+// their raw templates are the same.
+//
+// Templates are compared part by part from the beginning. Literal
+// characters are more specific than varspecs, varspecs with the prefix
+// modifier than ones without, and ones without the explode modifier than
+// ones with it. Of varspecs of the same modifier, ones in {+...} and
+// {#...} are less specific. If one template runs out of parts first, the
+// other one is more specific. Ties are broken by the raw templates.
+func Compare(t1, t2 *Template) int {
+	r1, r2 := t1.specificity(), t2.specificity()
+	for i := 0; i < len(r1) && i < len(r2); i++ {
+		if r1[i] != r2[i] {
+			return r1[i] - r2[i]
+		}
+	}
+	if len(r1) != len(r2) {
+		return len(r2) - len(r1)
+	}
+	return strings.Compare(t1.raw, t2.raw)
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2016 Kohei YOSHIDA. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of The BSD 3-Clause License
+// that can be found in the LICENSE file.
+
+package uritemplate
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func ExampleCompare() {
+	tmpls := []*Template{
+		MustNew("/users/{id}"),
+		MustNew("/{+path}"),
+		MustNew("/users/me"),
+		MustNew("/users/{id:3}"),
+		MustNew("/{collection}/{id}"),
+	}
+	sort.Slice(tmpls, func(i, j int) bool {
+		return Compare(tmpls[i], tmpls[j]) < 0
+	})
+	for _, tmpl := range tmpls {
+		fmt.Println(tmpl)
+	}
+
+	// Output:
+	// /users/me
+	// /users/{id:3}
+	// /users/{id}
+	// /{collection}/{id}
+	// /{+path}
+}
+
+func TestSubsumes(t *testing.T) {
+	for _, c := range []struct {
+		general  string
+		specific string
+		expected bool
+	}{
+		{"/users/{id}", "/users/me", true},
+		{"/users/me", "/users/{id}", false},
+		{"/users/{id}", "/users/{id:3}", true},
+		{"/users/{id:3}", "/users/{id}", false},
+		{"/{+path}", "/users/{id}", true},
+		{"/users/{id}", "/{+path}", false},
+		{"{/path*}", "/a/b", true},
+		{"{/path*}", "/a/{b}", false}, // b may be a list joined by ","
+		{"{+path}", "/a/{b}", true},
+		{"{+path}", "{/a,b}", true},
+		{"{/a,b}", "{/path*}", false},
+		{"/search{?q,lang}", "/search{?q}", true},
+		{"/search{?q}", "/search{?q,lang}", false},
+		{"/{x}", "/{y}", true},
+		{"/users/{id}", "/posts/{id}", false},
+		{"/日本/{x}", "/日本/{y:2}", true},
+	} {
+		if actual := Subsumes(MustNew(c.general), MustNew(c.specific)); actual != c.expected {
+			t.Errorf("on %q and %q: expected %t, but got %t", c.general, c.specific, c.expected, actual)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	for _, c := range []struct {
+		t1       string
+		t2       string
+		expected int
+	}{
+		{"/users/me", "/users/{id}", -1},
+		{"/users/{id:3}", "/users/{id}", -1},
+		{"{/list}", "{/list*}", -1},
+		{"/{id}", "/{+id}", -1},
+		{"/{+id:3}", "/{id}", -1},
+		{"/users/{id}/posts", "/users/{id}", -1},
+		{"/users/{id}", "/users/{id}", 0},
+		{"/{a}", "/{b}", -1},
+	} {
+		t1, t2 := MustNew(c.t1), MustNew(c.t2)
+		if actual := sign(Compare(t1, t2)); actual != c.expected {
+			t.Errorf("on %q and %q: expected %d, but got %d", c.t1, c.t2, c.expected, actual)
+		}
+		if actual := sign(Compare(t2, t1)); actual != -c.expected {
+			t.Errorf("on %q and %q: expected %d, but got %d", c.t2, c.t1, -c.expected, actual)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
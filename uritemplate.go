@@ -7,6 +7,7 @@
 package uritemplate
 
 import (
+	"io"
 	"log"
 	"regexp"
 	"strings"
@@ -35,6 +36,7 @@ type Template struct {
 	varnames []string
 	re       *regexp.Regexp
 	prog     *prog
+	progs    map[string]*prog
 }
 
 // New parses and constructs a new Template instance based on the template.
@@ -57,6 +59,41 @@ func (t *Template) Raw() string {
 	return t.raw
 }
 
+// String returns the template rebuilt from its parsed form.
+func (t *Template) String() string {
+	var b strings.Builder
+	for i := range t.exprs {
+		switch expr := t.exprs[i].(type) {
+		default:
+			panic("unhandled expression")
+		case literals:
+			b.WriteString(string(expr))
+		case *expression:
+			b.WriteString(expr.String())
+		}
+	}
+	return b.String()
+}
+
+// Normalize returns the canonical form of the template.
+// In literals, pct-encoded triplets are uppercased and those that
+// represent unreserved characters are decoded.
+// Variable names are preserved as they are.
+func (t *Template) Normalize() *Template {
+	var b strings.Builder
+	for i := range t.exprs {
+		switch expr := t.exprs[i].(type) {
+		default:
+			panic("unhandled expression")
+		case literals:
+			normalizeLiterals(&b, string(expr))
+		case *expression:
+			b.WriteString(expr.String())
+		}
+	}
+	return MustNew(b.String())
+}
+
 // Varnames returns variable names used in the template.
 func (t *Template) Varnames() []string {
 	t.mu.Lock()
@@ -84,16 +121,56 @@ func (t *Template) Varnames() []string {
 	return t.varnames
 }
 
-// Expand returns a URI reference corresponding to the template expanded using the passed variables.
-func (t *Template) Expand(vars Values) (string, error) {
-	var w strings.Builder
+func (t *Template) expand(w writer, vars Values) error {
 	for i := range t.exprs {
 		expr := t.exprs[i]
-		if err := expr.expand(&w, vars); err != nil {
-			return w.String(), err
+		if err := expr.expand(w, vars); err != nil {
+			return err
 		}
 	}
-	return w.String(), nil
+	return nil
+}
+
+// Expand returns a URI reference corresponding to the template expanded using the passed variables.
+// Prefix modifiers on lists and associative arrays fail with
+// ErrPrefixComposite.
+func (t *Template) Expand(vars Values) (string, error) {
+	var w strings.Builder
+	err := t.expand(&w, vars)
+	return w.String(), err
+}
+
+var appendWriterPool = sync.Pool{
+	New: func() interface{} {
+		return new(appendWriter)
+	},
+}
+
+// ExpandTo writes the URI reference corresponding to the template expanded
+// using the passed variables to w, and returns the number of bytes written.
+// Nothing is written to w if the expansion fails.
+func (t *Template) ExpandTo(w io.Writer, vars Values) (int, error) {
+	buf := appendWriterPool.Get().(*appendWriter)
+	defer appendWriterPool.Put(buf)
+
+	*buf = (*buf)[:0]
+	if err := t.expand(buf, vars); err != nil {
+		return 0, err
+	}
+	return w.Write(*buf)
+}
+
+// AppendExpand appends the URI reference corresponding to the template
+// expanded using the passed variables to dst and returns the extended
+// buffer. As Expand does, it returns what is expanded so far on error.
+func (t *Template) AppendExpand(dst []byte, vars Values) ([]byte, error) {
+	w := appendWriterPool.Get().(*appendWriter)
+	*w = dst
+	err := t.expand(w, vars)
+	dst = *w
+	*w = nil
+	appendWriterPool.Put(w)
+	return dst, err
 }
 
 // Regexp converts the template to regexp and returns compiled *regexp.Regexp.
@@ -104,13 +181,8 @@ func (t *Template) Regexp() *regexp.Regexp {
 		return t.re
 	}
 
-	var b strings.Builder
-	b.WriteByte('^')
-	for _, expr := range t.exprs {
-		expr.regexp(&b)
-	}
-	b.WriteByte('$')
-	t.re = regexp.MustCompile(b.String())
+	expr, _ := t.regexpString(RegexpOptions{})
+	t.re = regexp.MustCompile(expr)
 
 	return t.re
 }
@@ -7,6 +7,8 @@
 package uritemplate
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -175,6 +177,10 @@ var (
 		{"{&keys*}", "&semi=%3B&dot=.&comma=%2C", true},
 		// others
 		{"{special_chars}", "2001%3Adb8%3A%3A35", false},
+		{"{product}", "%E6%97%A5%E6%9C%AC%E8%AA%9E%E3%81%AE%E8%A3%BD%E5%93%81", false},
+		{"{product:3}", "%E6%97%A5%E6%9C%AC%E8%AA%9E", false},
+		{"{+product:4}", "%E6%97%A5%E6%9C%AC%E8%AA%9E%E3%81%AE", false},
+		{"{?product:3}", "?product=%E6%97%A5%E6%9C%AC%E8%AA%9E", false},
 	}
 	testExpressionExpandVarMap = Values{
 		"count":         List("one", "two", "three"),
@@ -194,6 +200,7 @@ var (
 		"empty":         String(""),
 		"empty_keys":    KV(),
 		"special_chars": String("2001:db8::35"),
+		"product":       String("日本語の製品"),
 		// undef is omitted. uritemplate.go treats variables that could not
 		// found in the varmap as null.
 	}
@@ -218,6 +225,98 @@ func TestTemplateExpand(t *testing.T) {
 	}
 }
 
+func TestTemplate_Expand_PctEncodedReserved(t *testing.T) {
+	vars := Values{
+		"path": String("/foo%2Fbar%20baz"),
+		"half": String("100%"),
+	}
+	for _, c := range []struct {
+		raw      string
+		expected string
+	}{
+		{"{+path}", "/foo%2Fbar%20baz"},
+		{"{#path}", "#/foo%2Fbar%20baz"},
+		{"{path}", "%2Ffoo%252Fbar%2520baz"},
+		{"{+half}", "100%25"},
+	} {
+		actual, err := MustNew(c.raw).Expand(vars)
+		if err != nil {
+			t.Errorf("unexpected error on %q: %#v", c.raw, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("on %q: expected: %q, got: %q", c.raw, c.expected, actual)
+		}
+	}
+}
+
+func TestTemplate_Expand_KVKeys(t *testing.T) {
+	vars := Values{
+		"keys": KV("a b", "1", "c/d", "", "é", "2"),
+	}
+	for _, c := range []struct {
+		raw      string
+		expected string
+	}{
+		{"{?keys*}", "?a%20b=1&c%2Fd=&%C3%A9=2"},
+		{"{;keys*}", ";a%20b=1;c%2Fd;%C3%A9=2"},
+		{"{&keys*}", "&a%20b=1&c%2Fd=&%C3%A9=2"},
+		{"{?keys}", "?keys=a%20b,1,c%2Fd,,%C3%A9,2"},
+		{"{+keys}", "a%20b,1,c/d,,%C3%A9,2"},
+	} {
+		actual, err := MustNew(c.raw).Expand(vars)
+		if err != nil {
+			t.Errorf("unexpected error on %q: %#v", c.raw, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("on %q: expected: %q, got: %q", c.raw, c.expected, actual)
+		}
+	}
+}
+
+func TestTemplate_Expand_PrefixComposite(t *testing.T) {
+	vars := Values{
+		"list": List("red", "green"),
+		"keys": KV("semi", ";", "dot", "."),
+	}
+	for _, raw := range []string{"{list:3}", "{?keys:1}", "X{.list:2}"} {
+		_, err := MustNew(raw).Expand(vars)
+		if !errors.Is(err, ErrPrefixComposite) {
+			t.Errorf("on %q: expected %v, got %#v", raw, ErrPrefixComposite, err)
+			continue
+		}
+		if err, ok := err.(*ExpandError); !ok || err.Varname == "" {
+			t.Errorf("on %q: expected ExpandError with Varname, got %#v", raw, err)
+		}
+	}
+}
+
+func TestTemplate_ExpandTo(t *testing.T) {
+	for _, c := range testTemplateCases {
+		tmpl := MustNew(c.raw)
+
+		var b bytes.Buffer
+		n, err := tmpl.ExpandTo(&b, testExpressionExpandVarMap)
+		if err != nil {
+			t.Errorf("unexpected error on %q: %#v", c.raw, err)
+			continue
+		}
+		if got := b.String(); c.expected != got || n != len(got) {
+			t.Errorf("on %q: expected: %#v, got: %#v (%d bytes)", c.raw, c.expected, got, n)
+		}
+
+		dst, err := tmpl.AppendExpand([]byte("<"), testExpressionExpandVarMap)
+		if err != nil {
+			t.Errorf("unexpected error on %q: %#v", c.raw, err)
+			continue
+		}
+		if got := string(dst); "<"+c.expected != got {
+			t.Errorf("on %q: expected: %#v, got: %#v", c.raw, "<"+c.expected, got)
+		}
+	}
+}
+
 func TestTemplateRegexp(t *testing.T) {
 	for _, c := range testTemplateCases {
 		tmpl, err := New(c.raw)
@@ -239,6 +338,31 @@ func TestTemplateRegexp_NotMatch(t *testing.T) {
 	}
 }
 
+func TestTemplate_String(t *testing.T) {
+	for _, c := range testTemplateCases {
+		tmpl := MustNew(c.raw)
+		if s := tmpl.String(); s != c.raw {
+			t.Errorf("on %q: unexpected String() %q", c.raw, s)
+		}
+	}
+}
+
+func TestTemplate_Normalize(t *testing.T) {
+	for _, c := range []struct {
+		raw      string
+		expected string
+	}{
+		{"/a%2fb/{x}", "/a%2Fb/{x}"},
+		{"/%7euser%2d%41/{x%2f}", "/~user-A/{x%2f}"},
+		{"%e3%81%82{+a:3,b*}", "%E3%81%82{+a:3,b*}"},
+	} {
+		tmpl := MustNew(c.raw).Normalize()
+		if s := tmpl.String(); s != c.expected {
+			t.Errorf("on %q: expected %q, got %q", c.raw, c.expected, s)
+		}
+	}
+}
+
 func BenchmarkExpressionExpand(b *testing.B) {
 	c := testTemplateCases[0]
 	tmpl, err := New(c.raw)
@@ -255,6 +379,24 @@ func BenchmarkExpressionExpand(b *testing.B) {
 	}
 }
 
+func BenchmarkExpressionAppendExpand(b *testing.B) {
+	c := testTemplateCases[0]
+	tmpl, err := New(c.raw)
+	if err != nil {
+		b.Errorf("got unexpected error; %#v", err)
+		return
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		if buf, err = tmpl.AppendExpand(buf[:0], testExpressionExpandVarMap); err != nil {
+			b.Errorf("got unexpected error; %#v", err)
+			return
+		}
+	}
+}
+
 func BenchmarkMatch(b *testing.B) {
 	tmpl := MustNew("https://{host}/users{/user}{/media}")
 	b.ResetTimer()
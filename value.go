@@ -6,8 +6,6 @@
 
 package uritemplate
 
-import "strings"
-
 // A varname containing pct-encoded characters is not the same variable as
 // a varname with those same characters decoded.
 //
@@ -86,17 +84,10 @@ func (v Value) Valid() bool {
 	}
 }
 
-func (v Value) expand(w *strings.Builder, spec varspec, exp *expression) error {
+func (v Value) expand(w writer, spec Varspec, exp *expression) error {
 	switch v.T {
 	case ValueTypeString:
 		val := v.V[0]
-		var maxlen int
-		if max := len(val); spec.maxlen < 1 || spec.maxlen > max {
-			maxlen = max
-		} else {
-			maxlen = spec.maxlen
-		}
-
 		if exp.named {
 			w.WriteString(spec.name)
 			if val == "" {
@@ -105,7 +96,17 @@ func (v Value) expand(w *strings.Builder, spec varspec, exp *expression) error {
 			}
 			w.WriteByte('=')
 		}
-		return exp.escape(w, val[:maxlen])
+		return exp.escape(w, prefix(val, spec.maxlen))
+	}
+
+	// Prefix modifiers are not applicable to variables that have
+	// composite values.
+	//
+	// -- https://tools.ietf.org/html/rfc6570#section-2.4.1
+	if spec.maxlen > 0 {
+		return &ExpandError{Err: ErrPrefixComposite}
+	}
+	switch v.T {
 	case ValueTypeList:
 		var sep string
 		if spec.explode {
@@ -152,13 +153,10 @@ func (v Value) expand(w *strings.Builder, spec varspec, exp *expression) error {
 		}
 
 		var ifemp string
-		var kescape escapeFunc
 		if spec.explode && exp.named {
 			ifemp = exp.ifemp
-			kescape = escapeLiteral
 		} else {
 			ifemp = ","
-			kescape = exp.escape
 		}
 
 		if !spec.explode && exp.named {
@@ -170,7 +168,7 @@ func (v Value) expand(w *strings.Builder, spec varspec, exp *expression) error {
 			if i > 0 {
 				w.WriteString(sep)
 			}
-			if err := kescape(w, v.V[i]); err != nil {
+			if err := exp.escape(w, v.V[i]); err != nil {
 				return err
 			}
 			if v.V[i+1] == "" {
@@ -187,6 +185,21 @@ func (v Value) expand(w *strings.Builder, spec varspec, exp *expression) error {
 	return nil
 }
 
+// prefix returns at most maxlen leading characters, that is Unicode code
+// points, of s. It returns s as it is if maxlen is less than 1.
+func prefix(s string, maxlen int) string {
+	if maxlen < 1 || maxlen >= len(s) {
+		return s
+	}
+	for i := range s {
+		if maxlen == 0 {
+			return s[:i]
+		}
+		maxlen--
+	}
+	return s
+}
+
 // String returns Value that represents string.
 func String(v string) Value {
 	return Value{